@@ -2,32 +2,49 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"kimo/auth"
 	"kimo/config"
+	"kimo/tlsutil"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/cenkalti/log"
 	gopsutilNet "github.com/shirou/gopsutil/v4/net"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+// Version is the version of kimo, set at build time with -ldflags "-X kimo/agent.Version=<version>".
+var Version = "dev"
+
 // Agent is type for handling agent operations
 type Agent struct {
-	Config   *config.AgentConfig
-	conns    []Conn
-	Hostname string
-	mu       sync.RWMutex // protects conns
-	httpSrv  http.Server
+	Config     *config.AgentConfig
+	conns      []Conn
+	Hostname   string
+	mu         sync.RWMutex // protects conns
+	httpSrv    http.Server
+	containers *ContainerResolver // nil if container attribution is disabled
+	lookups    *lookupCache       // nil if connections are polled
+	tokens     *auth.Tokens
 }
 
 type Conn struct {
 	Port   uint32
 	Pid    int32
 	Status string
+
+	LocalIP    string
+	RemoteIP   string
+	RemotePort uint32
 }
 
 // NewAgent creates an returns a new Agent
@@ -36,10 +53,19 @@ func NewAgent(cfg *config.AgentConfig) *Agent {
 		Config:   cfg,
 		Hostname: getHostname(),
 	}
+	if cfg.Container.Enabled {
+		a.containers = NewContainerResolver(&cfg.Container)
+	}
+	if cfg.OnDemand {
+		a.lookups = newLookupCache(cfg.CacheTTL, cfg.RemotePorts)
+	}
 
 	// create http server
+	a.tokens = auth.NewTokens(cfg.Auth)
 	mux := http.NewServeMux()
-	mux.HandleFunc("/proc", a.Process)
+	mux.Handle("/proc", a.tokens.HandlerFunc(a.Process))
+	mux.Handle("POST /v1/lookup", a.tokens.HandlerFunc(a.Lookup))
+	mux.Handle("GET /v1/info", a.tokens.HandlerFunc(a.Info))
 	a.httpSrv = http.Server{
 		Addr:    a.Config.ListenAddress,
 		Handler: mux,
@@ -62,13 +88,29 @@ func (a *Agent) GetConns() []Conn {
 	return a.conns
 }
 
+// findConns returns connections those may match given queries,
+// either from the last poll or from an on demand lookup.
+func (a *Agent) findConns(ctx context.Context, queries []connQuery) ([]Conn, error) {
+	if a.lookups == nil {
+		return a.GetConns(), nil
+	}
+	ports := make([]uint32, len(queries))
+	for i, q := range queries {
+		ports[i] = q.LocalPort
+	}
+	return a.lookups.Get(ctx, ports)
+}
+
 func (a *Agent) ConvertConns(gopsConns []gopsutilNet.ConnectionStat) []Conn {
 	conns := make([]Conn, 0)
 	for _, cs := range gopsConns {
 		conn := Conn{
-			Port:   cs.Laddr.Port,
-			Status: cs.Status,
-			Pid:    cs.Pid,
+			Port:       cs.Laddr.Port,
+			Status:     cs.Status,
+			Pid:        cs.Pid,
+			LocalIP:    normalizeIP(cs.Laddr.IP),
+			RemoteIP:   normalizeIP(cs.Raddr.IP),
+			RemotePort: cs.Raddr.Port,
 		}
 		conns = append(conns, conn)
 	}
@@ -87,25 +129,83 @@ func getHostname() string {
 
 // Run starts the http server and begins listening for HTTP requests.
 func (a *Agent) Run() error {
+	if a.Config.OnDemand && (runtime.GOOS != "linux" || a.Config.Collector != CollectorProcfs) {
+		return errors.New("on_demand requires procfs collector, which is supported on Linux only")
+	}
+	if a.Config.Push.ServerURL != "" && len(a.Config.RemotePorts) == 0 {
+		return errors.New("push mode requires remote_ports, otherwise all TCP connections of the host are pushed")
+	}
+
 	log.Infof("Running server on %s \n", a.Config.ListenAddress)
 
 	errChan := make(chan error, 1)
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	var tlsFiles *tlsutil.Files
+	if a.Config.TLS.Enabled() {
+		tlsFiles = tlsutil.NewFiles(a.Config.TLS)
+		tlsConfig, err := tlsFiles.ServerConfig()
+		if err != nil {
+			return fmt.Errorf("tls config error: %w", err)
+		}
+		a.httpSrv.TLSConfig = tlsConfig
+	}
+
+	// Start gRPC server in a goroutine
+	var grpcSrv *grpc.Server
+	if a.Config.GRPCListenAddress != "" {
+		var opts []grpc.ServerOption
+		if tlsFiles != nil {
+			tlsConfig, err := tlsFiles.ServerConfig("h2")
+			if err != nil {
+				return fmt.Errorf("tls config error: %w", err)
+			}
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		lis, err := net.Listen("tcp", a.Config.GRPCListenAddress)
+		if err != nil {
+			return fmt.Errorf("grpc listen error: %w", err)
+		}
+		log.Infof("Running gRPC server on %s \n", a.Config.GRPCListenAddress)
+		grpcSrv = a.newGRPCServer(opts...)
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				errChan <- fmt.Errorf("grpc server error: %w", err)
+			}
+		}()
+	}
+
 	// Start server in a goroutine
 	go func() {
-		if err := a.httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if a.httpSrv.TLSConfig != nil {
+			err = a.httpSrv.ListenAndServeTLS("", "") // certificates are given by TLSConfig.
+		} else {
+			err = a.httpSrv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errChan <- fmt.Errorf("http server error: %w", err)
 		}
 	}()
 
 	// Poll connections in a goroutine
-	go func() {
-		if err := a.pollConns(ctx); err != nil {
-			errChan <- fmt.Errorf("agent polling error: %w", err)
-		}
-	}()
+	if a.lookups == nil {
+		go func() {
+			if err := a.pollConns(ctx); err != nil {
+				errChan <- fmt.Errorf("agent polling error: %w", err)
+			}
+		}()
+	}
+
+	// Push snapshots in a goroutine
+	if a.Config.Push.ServerURL != "" {
+		go func() {
+			if err := a.pushSnapshots(ctx); err != nil {
+				errChan <- fmt.Errorf("agent push error: %w", err)
+			}
+		}()
+	}
 
 	// Wait for interrupt signal or error
 	select {
@@ -120,6 +220,18 @@ func (a *Agent) Run() error {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop() // waits for open streams.
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcSrv.Stop()
+		}
+	}
 	if err := a.httpSrv.Shutdown(shutdownCtx); err != nil {
 		log.Infof("HTTP server shutdown failed: %v", err)
 		return err
@@ -0,0 +1,314 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kimo/config"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Container runtimes
+const (
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+	RuntimeCRIO       = "cri-o"
+)
+
+// Container holds container and kubernetes pod attribution of a process.
+type Container struct {
+	ID           string
+	Runtime      string
+	Name         string
+	PodUID       string
+	PodName      string
+	PodNamespace string
+}
+
+var (
+	// last segment of a cgroup path, e.g. docker-<id>.scope, cri-containerd-<id>.scope, crio-<id>.scope or <id>
+	cgroupContainerRegexp = regexp.MustCompile(`^(docker-|cri-containerd-|crio-)?([0-9a-f]{64})(\.scope)?$`)
+	// pod segment of a cgroup path, e.g. pod<uid> or kubepods-burstable-pod<uid_with_underscores>.slice
+	cgroupPodRegexp = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+	// mountinfo is used when cgroup paths are hidden by cgroup namespaces.
+	mountDockerRegexp     = regexp.MustCompile(`/docker/containers/([0-9a-f]{64})/`)
+	mountContainerdRegexp = regexp.MustCompile(`/io\.containerd\.runtime\.v2\.task/[^/]+/([0-9a-f]{64})/`)
+	mountCRIORegexp       = regexp.MustCompile(`/overlay-containers/([0-9a-f]{64})/`)
+	mountPodRegexp        = regexp.MustCompile(`/kubelet/pods/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})/`)
+)
+
+// procPath returns the path of given file under proc filesystem, respecting HOST_PROC like gopsutil does.
+func procPath(elem ...string) string {
+	root := os.Getenv("HOST_PROC")
+	if root == "" {
+		root = "/proc"
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// parseCgroup derives the container id, runtime and pod uid from the content of /proc/<pid>/cgroup.
+func parseCgroup(content string) Container {
+	var c Container
+	for _, line := range strings.Split(content, "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+		segments := strings.Split(path, "/")
+
+		if m := cgroupContainerRegexp.FindStringSubmatch(segments[len(segments)-1]); m != nil {
+			c.ID = m[2]
+			switch m[1] {
+			case "docker-":
+				c.Runtime = RuntimeDocker
+			case "cri-containerd-":
+				c.Runtime = RuntimeContainerd
+			case "crio-":
+				c.Runtime = RuntimeCRIO
+			default:
+				if strings.Contains(path, "/docker/") {
+					c.Runtime = RuntimeDocker
+				}
+			}
+		}
+		if m := cgroupPodRegexp.FindStringSubmatch(path); m != nil {
+			c.PodUID = strings.ReplaceAll(m[1], "_", "-")
+		}
+		if c.ID != "" {
+			return c
+		}
+	}
+	return c
+}
+
+// parseMountinfo derives the container id, runtime and pod uid from the content of /proc/<pid>/mountinfo.
+func parseMountinfo(content string) Container {
+	var c Container
+	for _, line := range strings.Split(content, "\n") {
+		if c.ID == "" {
+			if m := mountDockerRegexp.FindStringSubmatch(line); m != nil {
+				c.ID, c.Runtime = m[1], RuntimeDocker
+			} else if m := mountContainerdRegexp.FindStringSubmatch(line); m != nil {
+				c.ID, c.Runtime = m[1], RuntimeContainerd
+			} else if m := mountCRIORegexp.FindStringSubmatch(line); m != nil {
+				c.ID, c.Runtime = m[1], RuntimeCRIO
+			}
+		}
+		if c.PodUID == "" {
+			if m := mountPodRegexp.FindStringSubmatch(line); m != nil {
+				c.PodUID = m[1]
+			}
+		}
+	}
+	return c
+}
+
+// findContainer finds the container of the process with given pid.
+// Returns nil if the process does not run in a container.
+func findContainer(pid int32) *Container {
+	content, err := os.ReadFile(procPath(fmt.Sprint(pid), "cgroup"))
+	if err != nil {
+		log.Debugf("Can not read cgroup of %d: %s\n", pid, err)
+		return nil
+	}
+	c := parseCgroup(string(content))
+	if c.ID != "" && c.Runtime != "" && c.PodUID != "" {
+		return &c
+	}
+
+	content, err = os.ReadFile(procPath(fmt.Sprint(pid), "mountinfo"))
+	if err != nil {
+		log.Debugf("Can not read mountinfo of %d: %s\n", pid, err)
+	} else {
+		mc := parseMountinfo(string(content))
+		if c.ID == "" {
+			c.ID = mc.ID
+		}
+		if c.Runtime == "" && c.ID == mc.ID {
+			c.Runtime = mc.Runtime
+		}
+		if c.PodUID == "" {
+			c.PodUID = mc.PodUID
+		}
+	}
+
+	if c.ID == "" && c.PodUID == "" {
+		return nil
+	}
+	return &c
+}
+
+// containerMapping is the format of the static mapping file.
+type containerMapping struct {
+	Containers map[string]string `yaml:"containers"` // container id (or its prefix) -> name
+	Pods       map[string]string `yaml:"pods"`       // pod uid -> namespace/name
+}
+
+// containerNamesTTL is the duration that names of the containers those are not seen anymore are kept.
+const containerNamesTTL = time.Hour
+
+// containerNames is a cache entry of the resolved names of a container.
+type containerNames struct {
+	name, podName, podNamespace string
+	lastSeen                    time.Time
+}
+
+// ContainerResolver attributes processes to containers and resolves container and pod names.
+type ContainerResolver struct {
+	Config  *config.ContainerConfig
+	mapping containerMapping
+
+	mu    sync.Mutex
+	names map[string]*containerNames // cache of resolved names by container id
+}
+
+// NewContainerResolver creates and returns a new ContainerResolver.
+func NewContainerResolver(cfg *config.ContainerConfig) *ContainerResolver {
+	cr := &ContainerResolver{
+		Config: cfg,
+		names:  make(map[string]*containerNames),
+	}
+	if cfg.MappingFile != "" {
+		content, err := os.ReadFile(cfg.MappingFile)
+		if err != nil {
+			log.Errorf("Can not read container mapping file: %s\n", err)
+		} else if err = yaml.Unmarshal(content, &cr.mapping); err != nil {
+			log.Errorf("Can not parse container mapping file: %s\n", err)
+		}
+	}
+	return cr
+}
+
+// Attribute sets container fields of given process.
+func (cr *ContainerResolver) Attribute(p *Process) {
+	c := findContainer(p.Pid)
+	if c == nil {
+		return
+	}
+	cr.resolveNames(c)
+
+	p.ContainerID = c.ID
+	p.ContainerRuntime = c.Runtime
+	p.ContainerName = c.Name
+	p.PodUID = c.PodUID
+	p.PodName = c.PodName
+	p.PodNamespace = c.PodNamespace
+}
+
+// resolveNames sets names of the container and its pod from the mapping file or the runtime socket.
+func (cr *ContainerResolver) resolveNames(c *Container) {
+	cr.mu.Lock()
+	cached, ok := cr.names[c.ID]
+	if ok {
+		cached.lastSeen = time.Now()
+		c.Name, c.PodName, c.PodNamespace = cached.name, cached.podName, cached.podNamespace
+	}
+	cr.mu.Unlock()
+	if ok {
+		return
+	}
+
+	for id, name := range cr.mapping.Containers {
+		if c.ID != "" && strings.HasPrefix(c.ID, id) {
+			c.Name = name
+			break
+		}
+	}
+	if name, ok := cr.mapping.Pods[c.PodUID]; ok {
+		c.PodNamespace, c.PodName, ok = strings.Cut(name, "/")
+		if !ok {
+			c.PodNamespace, c.PodName = "", name
+		}
+	}
+
+	if c.Name == "" && c.ID != "" {
+		var err error
+		switch c.Runtime {
+		case RuntimeDocker:
+			err = inspectContainer(cr.Config.DockerSocket, "/containers/"+c.ID+"/json", c)
+		case RuntimeCRIO:
+			err = inspectContainer(cr.Config.CRIOSocket, "/containers/"+c.ID, c)
+		}
+		if err != nil {
+			log.Debugf("Can not inspect container %s: %s\n", c.ID, err)
+			return // do not cache, runtime might be temporarily unavailable.
+		}
+	}
+
+	if c.ID != "" {
+		now := time.Now()
+		cr.mu.Lock()
+		// containers those are not seen for a while are probably removed.
+		for id, cached := range cr.names {
+			if now.Sub(cached.lastSeen) > containerNamesTTL {
+				delete(cr.names, id)
+			}
+		}
+		cr.names[c.ID] = &containerNames{name: c.Name, podName: c.PodName, podNamespace: c.PodNamespace, lastSeen: now}
+		cr.mu.Unlock()
+	}
+}
+
+// inspectContainer gets container and pod names from a docker compatible or cri-o runtime socket.
+func inspectContainer(socket string, path string, c *Container) error {
+	if socket == "" {
+		return nil
+	}
+	client := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	response, err := client.Get("http://runtime" + path)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP request failed: %s", response.Status)
+	}
+
+	// docker returns labels under Config, cri-o returns them at top level.
+	var r struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&r)
+	if err != nil {
+		return err
+	}
+	labels := r.Labels
+	if len(labels) == 0 {
+		labels = r.Config.Labels
+	}
+
+	c.Name = strings.TrimPrefix(r.Name, "/")
+	if name := labels["io.kubernetes.container.name"]; name != "" {
+		c.Name = name
+	}
+	if c.PodName == "" {
+		c.PodName = labels["io.kubernetes.pod.name"]
+		c.PodNamespace = labels["io.kubernetes.pod.namespace"]
+	}
+	return nil
+}
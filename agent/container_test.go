@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testContainerID = "3f4e5d6c7b8a99887766554433221100ffeeddccbbaa00112233445566778899"
+	testPodUID      = "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"
+)
+
+func TestParseCgroup(t *testing.T) {
+	podUnderscored := strings.ReplaceAll(testPodUID, "-", "_")
+	tests := []struct {
+		name    string
+		content string
+		want    Container
+	}{
+		{"host process", "0::/user.slice/user-1000.slice/session-1.scope\n", Container{}},
+		{"docker cgroup v1", "12:memory:/docker/" + testContainerID + "\n11:cpu:/docker/" + testContainerID + "\n",
+			Container{ID: testContainerID, Runtime: RuntimeDocker}},
+		{"docker systemd", "0::/system.slice/docker-" + testContainerID + ".scope\n",
+			Container{ID: testContainerID, Runtime: RuntimeDocker}},
+		{"containerd pod", "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + podUnderscored +
+			".slice/cri-containerd-" + testContainerID + ".scope\n",
+			Container{ID: testContainerID, Runtime: RuntimeContainerd, PodUID: testPodUID}},
+		{"crio pod", "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod" + podUnderscored +
+			".slice/crio-" + testContainerID + ".scope\n",
+			Container{ID: testContainerID, Runtime: RuntimeCRIO, PodUID: testPodUID}},
+		{"cgroupfs pod", "4:pids:/kubepods/burstable/pod" + testPodUID + "/" + testContainerID + "\n",
+			Container{ID: testContainerID, PodUID: testPodUID}},
+		{"namespaced cgroup", "0::/\n", Container{}},
+		{"short id", "0::/system.slice/docker-3f4e5d6c.scope\n", Container{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCgroup(tt.content); got != tt.want {
+				t.Errorf("parseCgroup() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMountinfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Container
+	}{
+		{"host process", "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n", Container{}},
+		{"docker", "523 501 8:1 /var/lib/docker/containers/" + testContainerID +
+			"/resolv.conf /etc/resolv.conf rw,relatime - ext4 /dev/sda1 rw\n",
+			Container{ID: testContainerID, Runtime: RuntimeDocker}},
+		{"containerd pod", "610 598 8:1 /var/lib/kubelet/pods/" + testPodUID +
+			"/etc-hosts /etc/hosts rw,relatime - ext4 /dev/sda1 rw\n" +
+			"611 598 0:24 /run/containerd/io.containerd.runtime.v2.task/k8s.io/" + testContainerID +
+			"/hostname /etc/hostname rw,nosuid - tmpfs tmpfs rw\n",
+			Container{ID: testContainerID, Runtime: RuntimeContainerd, PodUID: testPodUID}},
+		{"crio", "700 690 0:24 /containers/storage/overlay-containers/" + testContainerID +
+			"/userdata/hostname /etc/hostname rw,nosuid - tmpfs tmpfs rw\n",
+			Container{ID: testContainerID, Runtime: RuntimeCRIO}},
+		{"pod only", "610 598 8:1 /var/lib/kubelet/pods/" + testPodUID +
+			"/volumes/kubernetes.io~empty-dir/cache /cache rw,relatime - ext4 /dev/sda1 rw\n",
+			Container{PodUID: testPodUID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMountinfo(tt.content); got != tt.want {
+				t.Errorf("parseMountinfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
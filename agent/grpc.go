@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"kimo/agentpb"
+	"kimo/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer implements the gRPC service of the agent.
+type grpcServer struct {
+	agentpb.UnimplementedAgentServer
+	agent *Agent
+}
+
+// Info returns the hostname and capabilities of the agent.
+func (s *grpcServer) Info(ctx context.Context, req *agentpb.InfoRequest) (*agentpb.InfoResponse, error) {
+	info := s.agent.info()
+	return &agentpb.InfoResponse{
+		Hostname:     info.Hostname,
+		Version:      info.Version,
+		Capabilities: info.Capabilities,
+		GrpcPort:     info.GRPCPort,
+	}, nil
+}
+
+// Lookup finds the processes of given connections and fds.
+func (s *grpcServer) Lookup(ctx context.Context, req *agentpb.LookupRequest) (*agentpb.LookupResponse, error) {
+	lr := lookupRequestFromPB(req)
+	queries, err := lr.queries()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	response, err := s.agent.lookup(ctx, lr, queries)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can not look up connections: %s", err)
+	}
+	return lookupResponseToPB(s.agent.Hostname, response), nil
+}
+
+// Stream serves lookups over a single stream until the client closes it.
+func (s *grpcServer) Stream(stream agentpb.Agent_StreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.Lookup(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		err = stream.Send(resp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// newGRPCServer creates a gRPC server with the service of the agent.
+// Requests are authenticated with bearer tokens in "authorization" metadata if tokens are configured.
+func (a *Agent) newGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	if a.tokens.Enabled() {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := a.authenticateGRPC(ctx); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := a.authenticateGRPC(ss.Context()); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}),
+		)
+	}
+	s := grpc.NewServer(opts...)
+	agentpb.RegisterAgentServer(s, &grpcServer{agent: a})
+	return s
+}
+
+// authenticateGRPC checks the bearer token in the metadata of the request.
+func (a *Agent) authenticateGRPC(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, h := range md.Get("authorization") {
+		if a.tokens.Valid(auth.BearerToken(h)) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func lookupRequestFromPB(req *agentpb.LookupRequest) *LookupRequest {
+	lr := &LookupRequest{Conns: make([]LookupConn, len(req.Conns)), Fds: req.Fds}
+	for i, c := range req.Conns {
+		lr.Conns[i] = LookupConn{LocalIP: c.LocalIp, LocalPort: c.LocalPort, Remote: c.Remote}
+	}
+	return lr
+}
+
+func lookupResponseToPB(hostname string, response *LookupResponse) *agentpb.LookupResponse {
+	results := map[string]agentpb.LookupResult_Result{
+		LookupFound:     agentpb.LookupResult_FOUND,
+		LookupNotFound:  agentpb.LookupResult_NOT_FOUND,
+		LookupAmbiguous: agentpb.LookupResult_AMBIGUOUS,
+	}
+	resp := &agentpb.LookupResponse{Hostname: hostname, Version: Version, Results: make([]*agentpb.LookupResult, len(response.Results))}
+	for i, r := range response.Results {
+		pr := &agentpb.LookupResult{Fd: r.Fd, Result: results[r.Result]}
+		if r.Conn != nil {
+			pr.Conn = &agentpb.Conn{LocalIp: r.Conn.LocalIP, LocalPort: r.Conn.LocalPort, Remote: r.Conn.Remote}
+		}
+		for _, p := range r.Processes {
+			pr.Processes = append(pr.Processes, processToPB(p))
+		}
+		resp.Results[i] = pr
+	}
+	return resp
+}
+
+func processToPB(p *Process) *agentpb.Process {
+	pp := &agentpb.Process{
+		Status:           p.Status,
+		Pid:              p.Pid,
+		Port:             p.Port,
+		Fd:               p.Fd,
+		Name:             p.Name,
+		Cmdline:          p.CmdLine,
+		LocalAddress:     p.LocalAddress,
+		RemoteAddress:    p.RemoteAddress,
+		Candidates:       int32(p.Candidates),
+		ContainerId:      p.ContainerID,
+		ContainerRuntime: p.ContainerRuntime,
+		ContainerName:    p.ContainerName,
+		PodUid:           p.PodUID,
+		PodName:          p.PodName,
+		PodNamespace:     p.PodNamespace,
+		Uid:              p.UID,
+		Username:         p.Username,
+		Ppid:             p.PPid,
+		ParentCmdline:    p.ParentCmdLine,
+		Cwd:              p.Cwd,
+		Exe:              p.Exe,
+		Rss:              p.RSS,
+		NumFds:           p.NumFDs,
+		Tags:             p.Tags,
+		RemoteMismatch:   p.RemoteMismatch,
+	}
+	if p.StartTime != nil {
+		pp.StartTime = p.StartTime.UnixMilli()
+	}
+	return pp
+}
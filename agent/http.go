@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cenkalti/log"
 	gopsutilProcess "github.com/shirou/gopsutil/v4/process"
@@ -16,8 +17,34 @@ type Process struct {
 	Status  string `json:"status"`
 	Pid     int32  `json:"pid"`
 	Port    uint32 `json:"port"`
+	Fd      int32  `json:"fd,omitempty"` // fd of the unix socket connection in the database server process.
 	Name    string `json:"name"`
 	CmdLine string `json:"cmdline"`
+
+	LocalAddress  string `json:"local_address,omitempty"`
+	RemoteAddress string `json:"remote_address,omitempty"`
+	Candidates    int    `json:"candidates,omitempty"` // number of connections matching the query, set if it is ambiguous.
+	// connection is matched by the port only, it is to another address than the remote of the query.
+	RemoteMismatch bool `json:"remote_mismatch,omitempty"`
+
+	ContainerID      string `json:"container_id,omitempty"`
+	ContainerRuntime string `json:"container_runtime,omitempty"`
+	ContainerName    string `json:"container_name,omitempty"`
+	PodUID           string `json:"pod_uid,omitempty"`
+	PodName          string `json:"pod_name,omitempty"`
+	PodNamespace     string `json:"pod_namespace,omitempty"`
+
+	UID           *uint32    `json:"uid,omitempty"`
+	Username      string     `json:"username,omitempty"`
+	PPid          int32      `json:"ppid,omitempty"`
+	ParentCmdLine string     `json:"parent_cmdline,omitempty"`
+	StartTime     *time.Time `json:"start_time,omitempty"`
+	Cwd           string     `json:"cwd,omitempty"`
+	Exe           string     `json:"exe,omitempty"`
+	RSS           uint64     `json:"rss,omitempty"`
+	NumFDs        int32      `json:"num_fds,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"` // allowlisted environment variables
 }
 
 // Response contains basic process information for API responses.
@@ -25,96 +52,132 @@ type Response struct {
 	Processes []*Process `json:"processes"`
 }
 
-// parsePortsParam parses and returns port numbers from the request.
-func parsePortsParam(w http.ResponseWriter, req *http.Request) ([]uint32, error) {
-	portsParam := req.URL.Query().Get("ports")
-	log.Debugf("Looking for process(es) for ports: %s\n", portsParam)
+// parseNumbersParam parses and returns comma separated numbers (e.g. ports) from given param of the request.
+func parseNumbersParam(req *http.Request, name string) ([]uint32, error) {
+	param := req.URL.Query().Get(name)
+	log.Debugf("Looking for process(es) for %s: %s\n", name, param)
 
-	if portsParam == "" {
-		return nil, fmt.Errorf("ports param is required")
+	if param == "" {
+		return nil, nil
 	}
 
-	ports := strings.Split(portsParam, ",")
-
-	var portNumbers []uint32
-	for _, port := range ports {
-		p, err := strconv.Atoi(port)
+	var numbers []uint32
+	for _, s := range strings.Split(param, ",") {
+		n, err := strconv.ParseUint(s, 10, 32)
 		if err != nil {
-			return nil, fmt.Errorf("invalid port number: %s", port)
+			return nil, fmt.Errorf("invalid number in %s: %s", name, s)
 		}
-		portNumbers = append(portNumbers, uint32(p))
+		numbers = append(numbers, uint32(n))
 	}
 
-	return portNumbers, nil
+	return numbers, nil
 }
 
-// findProcesses finds process(es) those have connections with given ports.
-func findProcesses(ports []uint32, conns []Conn) []*Process {
+// findProcesses finds process(es) those have connections matching given queries.
+// All candidates are returned if a query matches more than one connection.
+func findProcesses(queries []connQuery, conns []Conn) []*Process {
 	ps := make([]*Process, 0)
+	for _, q := range queries {
+		ps = append(ps, findQueryProcesses(q, conns)...)
+	}
+	return ps
+}
 
-	for _, conn := range conns {
-		if !portExists(conn.Port, ports) {
-			continue
-		}
-
-		process, err := gopsutilProcess.NewProcess(conn.Pid)
+// findQueryProcesses finds process(es) those have connections matching given query.
+func findQueryProcesses(q connQuery, conns []Conn) []*Process {
+	ps := make([]*Process, 0)
+	candidates, remoteMismatch := q.match(conns)
+	for _, conn := range candidates {
+		p, err := newProcess(conn.Pid)
 		if err != nil {
 			log.Debugf("Error occured while finding the process %s\n", err.Error())
 			continue
 		}
-
-		if process == nil {
-			log.Debugf("Process not found for %d\n", conn.Pid)
-			continue
-		}
-
-		name, err := process.Name()
-		if err != nil {
-			log.Debugf("Name not found for %d\n", process.Pid)
-		}
-
-		cmdline, err := process.Cmdline()
-		if err != nil {
-			log.Debugf("Cmdline not found for %d\n", process.Pid)
-		}
-
-		p := &Process{
-			Status:  conn.Status,
-			Pid:     conn.Pid,
-			Port:    conn.Port,
-			Name:    name,
-			CmdLine: cmdline,
+		p.Status = conn.Status
+		p.Port = conn.Port
+		p.LocalAddress = joinHostPort(conn.LocalIP, conn.Port)
+		p.RemoteAddress = joinHostPort(conn.RemoteIP, conn.RemotePort)
+		if len(candidates) > 1 {
+			p.Candidates = len(candidates)
 		}
+		p.RemoteMismatch = remoteMismatch
 
 		ps = append(ps, p)
 	}
 	return ps
 }
 
-func portExists(port uint32, ports []uint32) bool {
-	for _, p := range ports {
-		if p == port {
-			return true
-		}
+// newProcess creates a Process with the info of the process with given pid.
+func newProcess(pid int32) (*Process, error) {
+	process, err := gopsutilProcess.NewProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := process.Name()
+	if err != nil {
+		log.Debugf("Name not found for %d\n", process.Pid)
+	}
+
+	cmdline, err := process.Cmdline()
+	if err != nil {
+		log.Debugf("Cmdline not found for %d\n", process.Pid)
 	}
-	return false
+
+	return &Process{
+		Pid:     pid,
+		Name:    name,
+		CmdLine: cmdline,
+	}, nil
+}
+
+// setHeaders sets the hostname and version headers those are read by the server.
+func (a *Agent) setHeaders(w http.ResponseWriter) {
+	w.Header().Set("X-Kimo-Hostname", a.Hostname)
+	w.Header().Set("X-Kimo-Version", Version)
 }
 
 // Process is handler for serving process info
 func (a *Agent) Process(w http.ResponseWriter, req *http.Request) {
-	w.Header().Set("X-Kimo-Hostname", a.Hostname)
+	a.setHeaders(w)
+
+	ports, err := parseNumbersParam(req, "ports")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fds, err := parseNumbersParam(req, "fds")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	queries, err := parseConnsParam(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(ports) == 0 && len(fds) == 0 && len(queries) == 0 {
+		http.Error(w, "ports, conns or fds param is required", http.StatusBadRequest)
+		return
+	}
+	queries = addPortQueries(queries, ports)
 
-	// todo: cache result for a short period (10s? 30s?)
-	ports, err := parsePortsParam(w, req)
+	conns, err := a.findConns(req.Context(), queries)
 	if err != nil {
-		http.Error(w, "port params is required", http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Can not look up connections: %s", err), http.StatusInternalServerError)
 		return
 	}
-	ps := findProcesses(ports, a.GetConns())
+	ps := findProcesses(queries, conns)
+	if len(fds) > 0 {
+		ps = append(ps, a.findSocketProcesses(fds)...)
+	}
 	if len(ps) == 0 {
 		http.Error(w, "Connection(s) not found", http.StatusNotFound)
 		return
 	}
+	for _, p := range ps {
+		a.addProcessInfo(p)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	response := &Response{
@@ -125,3 +188,18 @@ func (a *Agent) Process(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, "Can not encode agent process", http.StatusInternalServerError)
 	}
 }
+
+// addProcessInfo adds optional metadata, environment tags and container attribution to given process.
+func (a *Agent) addProcessInfo(p *Process) {
+	addMetadata(p, &a.Config.Process)
+	if len(a.Config.EnvTags) > 0 {
+		var err error
+		p.Tags, err = readEnvTags(p.Pid, a.Config.EnvTags)
+		if err != nil {
+			log.Debugf("Environment not found for %d\n", p.Pid)
+		}
+	}
+	if a.containers != nil {
+		a.containers.Attribute(p)
+	}
+}
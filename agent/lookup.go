@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+)
+
+// lookupCache looks up connections of requested local ports on demand and caches them for a short period.
+// Ports requested while a lookup is running are coalesced into the next lookup,
+// so concurrent requests from multiple servers cause at most one lookup at a time.
+type lookupCache struct {
+	ttl         time.Duration
+	remotePorts []uint32 // only connections to these ports are looked up, all if empty
+
+	mu      sync.Mutex
+	entries map[uint32]lookupEntry // local port -> connections
+	pending map[uint32]bool        // ports to be looked up in the next call
+	next    *lookupCall            // call that pending ports will be looked up in, nil if there are no pending ports
+	running bool                   // true if lookup loop is running
+}
+
+type lookupEntry struct {
+	conns   []Conn
+	expires time.Time
+}
+
+// lookupCall is a lookup that requests wait for.
+type lookupCall struct {
+	done chan struct{}
+	err  error
+}
+
+func newLookupCache(ttl time.Duration, remotePorts []uint32) *lookupCache {
+	return &lookupCache{
+		ttl:         ttl,
+		remotePorts: remotePorts,
+		entries:     make(map[uint32]lookupEntry),
+		pending:     make(map[uint32]bool),
+	}
+}
+
+// Get returns connections those have one of given local ports.
+func (lc *lookupCache) Get(ctx context.Context, ports []uint32) ([]Conn, error) {
+	now := time.Now()
+	lc.mu.Lock()
+	var call *lookupCall
+	for _, port := range ports {
+		if e, ok := lc.entries[port]; ok && now.Before(e.expires) {
+			continue
+		}
+		lc.pending[port] = true
+		if lc.next == nil {
+			lc.next = &lookupCall{done: make(chan struct{})}
+		}
+		call = lc.next
+	}
+	if call != nil && !lc.running {
+		lc.running = true
+		go lc.loop()
+	}
+	lc.mu.Unlock()
+
+	if call != nil {
+		select {
+		case <-call.done:
+			if call.err != nil {
+				return nil, call.err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	conns := make([]Conn, 0)
+	for _, port := range ports {
+		conns = append(conns, lc.entries[port].conns...)
+	}
+	return conns, nil
+}
+
+// loop looks up pending ports until there is none left.
+func (lc *lookupCache) loop() {
+	for {
+		lc.mu.Lock()
+		call, ports := lc.next, lc.pending
+		if call == nil {
+			lc.running = false
+			lc.mu.Unlock()
+			return
+		}
+		lc.next, lc.pending = nil, make(map[uint32]bool)
+		lc.mu.Unlock()
+
+		call.err = lc.lookup(ports)
+		close(call.done)
+	}
+}
+
+// lookup finds connections of given ports and stores them in the cache.
+func (lc *lookupCache) lookup(ports map[uint32]bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	log.Debugf("Looking up connections of %d port(s)...\n", len(ports))
+	keepRemote := remotePortsFilter(lc.remotePorts)
+	conns, err := getProcfsConns(ctx, func(conn *Conn) bool {
+		return ports[conn.Port] && keepRemote(conn)
+	})
+	if err != nil {
+		return err
+	}
+
+	found := make(map[uint32][]Conn)
+	for _, conn := range conns {
+		found[conn.Port] = append(found[conn.Port], conn)
+	}
+
+	now := time.Now()
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	for port, e := range lc.entries {
+		if !now.Before(e.expires) {
+			delete(lc.entries, port)
+		}
+	}
+	for port := range ports {
+		// ports without connections are cached too, so that they are not looked up again and again.
+		lc.entries[port] = lookupEntry{conns: found[port], expires: now.Add(lc.ttl)}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// maxLookupRequestSize limits the body of lookup requests.
+const maxLookupRequestSize = 10 << 20
+
+// Lookup results
+const (
+	LookupFound     = "found"
+	LookupNotFound  = "not_found"
+	LookupAmbiguous = "ambiguous" // more than one connection matches, all candidates are returned.
+)
+
+// LookupRequest is the body of a lookup request.
+type LookupRequest struct {
+	Conns []LookupConn `json:"conns"`
+	Fds   []int32      `json:"fds,omitempty"` // fds of unix socket connections in the database server process.
+}
+
+// LookupConn is a connection to be looked up.
+type LookupConn struct {
+	LocalIP   string `json:"local_ip,omitempty"`
+	LocalPort uint32 `json:"local_port"`
+	Remote    string `json:"remote,omitempty"` // ip:port that the client is connected to.
+}
+
+// LookupResult is the result of a connection or a unix socket fd in a lookup request.
+type LookupResult struct {
+	Conn      *LookupConn `json:"conn,omitempty"`
+	Fd        int32       `json:"fd,omitempty"`
+	Result    string      `json:"result"`
+	Processes []*Process  `json:"processes,omitempty"`
+}
+
+// LookupResponse contains results in the order of the request, connections first then fds.
+type LookupResponse struct {
+	Results []*LookupResult `json:"results"`
+}
+
+// toQuery converts the lookup connection into a connection query.
+func (lc *LookupConn) toQuery() (connQuery, error) {
+	q := connQuery{LocalIP: normalizeIP(lc.LocalIP), LocalPort: lc.LocalPort}
+	if lc.Remote != "" {
+		var err error
+		q.RemoteIP, q.RemotePort, err = splitHostPort(lc.Remote)
+		if err != nil {
+			return q, fmt.Errorf("invalid remote %s: %w", lc.Remote, err)
+		}
+	}
+	return q, nil
+}
+
+// queries converts connections of the request into connection queries.
+func (lr *LookupRequest) queries() ([]connQuery, error) {
+	queries := make([]connQuery, len(lr.Conns))
+	for i := range lr.Conns {
+		var err error
+		queries[i], err = lr.Conns[i].toQuery()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return queries, nil
+}
+
+// Lookup is handler for looking up a batch of connections.
+// Unlike Process, it returns a result for each connection including the ones those are not found.
+func (a *Agent) Lookup(w http.ResponseWriter, req *http.Request) {
+	a.setHeaders(w)
+
+	var lr LookupRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxLookupRequestSize)).Decode(&lr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can not decode request: %s", err), http.StatusBadRequest)
+		return
+	}
+	queries, err := lr.queries()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := a.lookup(req.Context(), &lr, queries)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can not look up connections: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Can not encode lookup response", http.StatusInternalServerError)
+	}
+}
+
+// lookup finds the processes of the connections and fds in given request.
+func (a *Agent) lookup(ctx context.Context, lr *LookupRequest, queries []connQuery) (*LookupResponse, error) {
+	conns, err := a.findConns(ctx, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &LookupResponse{Results: make([]*LookupResult, 0, len(lr.Conns)+len(lr.Fds))}
+	for i, q := range queries {
+		r := newLookupResult(findQueryProcesses(q, conns))
+		r.Conn = &lr.Conns[i]
+		response.Results = append(response.Results, r)
+	}
+
+	if len(lr.Fds) > 0 {
+		fds := make([]uint32, len(lr.Fds))
+		for i, fd := range lr.Fds {
+			fds[i] = uint32(fd)
+		}
+		byFd := make(map[int32][]*Process)
+		for _, p := range a.findSocketProcesses(fds) {
+			byFd[p.Fd] = append(byFd[p.Fd], p)
+		}
+		for _, fd := range lr.Fds {
+			r := newLookupResult(byFd[fd])
+			r.Fd = fd
+			response.Results = append(response.Results, r)
+		}
+	}
+
+	for _, r := range response.Results {
+		for _, p := range r.Processes {
+			a.addProcessInfo(p)
+		}
+	}
+	return response, nil
+}
+
+// Info contains the hostname and capabilities of the agent for servers to choose the protocol.
+type Info struct {
+	Hostname     string   `json:"hostname"`
+	Version      string   `json:"version"`
+	Capabilities []string `json:"capabilities"`
+	GRPCPort     uint32   `json:"grpc_port,omitempty"`
+}
+
+// Capabilities
+const (
+	CapabilityLookup = "lookup" // POST /v1/lookup
+	CapabilityGRPC   = "grpc"   // gRPC service on GRPCPort
+	CapabilityStream = "stream" // Stream RPC of the gRPC service
+)
+
+// info returns the info of the agent.
+func (a *Agent) info() *Info {
+	info := &Info{Hostname: a.Hostname, Version: Version, Capabilities: []string{CapabilityLookup}}
+	if a.Config.GRPCListenAddress != "" {
+		info.Capabilities = append(info.Capabilities, CapabilityGRPC, CapabilityStream)
+		if _, port, err := splitHostPort(a.Config.GRPCListenAddress); err == nil {
+			info.GRPCPort = port
+		}
+	}
+	return info
+}
+
+// Info is handler for serving the info of the agent.
+func (a *Agent) Info(w http.ResponseWriter, req *http.Request) {
+	a.setHeaders(w)
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(a.info())
+	if err != nil {
+		http.Error(w, "Can not encode agent info", http.StatusInternalServerError)
+	}
+}
+
+// newLookupResult creates a result with given matching processes.
+func newLookupResult(ps []*Process) *LookupResult {
+	r := &LookupResult{Processes: ps}
+	switch len(ps) {
+	case 0:
+		r.Result = LookupNotFound
+	case 1:
+		r.Result = LookupFound
+	default:
+		r.Result = LookupAmbiguous
+	}
+	return r
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cenkalti/log"
+)
+
+// connQuery describes a connection to be found by its local address and optionally its remote address.
+// Local address is the client address as seen by the database (or the hop in front of it),
+// remote address is the address of the database (or the hop) the client is connected to.
+type connQuery struct {
+	LocalIP    string // empty if only the port is known
+	LocalPort  uint32
+	RemoteIP   string // empty if unknown
+	RemotePort uint32
+}
+
+// match returns the connections matching the query.
+// Connections matching both local and remote addresses are preferred. Listening sockets never match.
+// The remote address of the query is the database address that the server knows, clients may connect to
+// another address of it (e.g. a VIP or a NAT address). So, if no connection is to that address,
+// the only connection with the local port is returned with remoteMismatch set. If there are more,
+// none of them is returned since the port is shared by unrelated processes.
+func (q connQuery) match(conns []Conn) (matched []Conn, remoteMismatch bool) {
+	var byPort, byRemote, byBoth []Conn
+	for _, conn := range conns {
+		if conn.Port != q.LocalPort || conn.Status == "LISTEN" {
+			continue
+		}
+		byPort = append(byPort, conn)
+		if q.RemoteIP != "" && (conn.RemoteIP != q.RemoteIP || conn.RemotePort != q.RemotePort) {
+			continue
+		}
+		byRemote = append(byRemote, conn)
+
+		if q.LocalIP != "" && conn.LocalIP == q.LocalIP {
+			byBoth = append(byBoth, conn)
+		}
+	}
+
+	switch {
+	case len(byBoth) > 0:
+		return byBoth, false
+	case len(byRemote) > 0:
+		return byRemote, false
+	case len(byPort) == 1:
+		log.Debugf("Connection %s-%s does not match remote of %s, it is the only one with the port\n",
+			joinHostPort(byPort[0].LocalIP, byPort[0].Port), joinHostPort(byPort[0].RemoteIP, byPort[0].RemotePort), q)
+		return byPort, true
+	default:
+		if len(byPort) > 1 {
+			log.Debugf("None of %d connections with the port match remote of %s\n", len(byPort), q)
+		}
+		return nil, false
+	}
+}
+
+func (q connQuery) String() string {
+	s := joinHostPort(q.LocalIP, q.LocalPort)
+	if q.RemoteIP != "" {
+		s += "-" + joinHostPort(q.RemoteIP, q.RemotePort)
+	}
+	return s
+}
+
+// parseConnsParam parses connection queries from conns param of the request.
+// Connections are comma separated, each of them is "local" or "local-remote" address, e.g. 10.0.0.5:52134-10.0.0.9:3306
+func parseConnsParam(req *http.Request) ([]connQuery, error) {
+	param := req.URL.Query().Get("conns")
+	log.Debugf("Looking for process(es) for conns: %s\n", param)
+
+	if param == "" {
+		return nil, nil
+	}
+
+	var queries []connQuery
+	for _, s := range strings.Split(param, ",") {
+		local, remote, hasRemote := strings.Cut(s, "-")
+		var q connQuery
+		var err error
+		q.LocalIP, q.LocalPort, err = splitHostPort(local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid conn %s: %w", s, err)
+		}
+		if hasRemote {
+			q.RemoteIP, q.RemotePort, err = splitHostPort(remote)
+			if err != nil {
+				return nil, fmt.Errorf("invalid conn %s: %w", s, err)
+			}
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// addPortQueries adds port only queries for given ports those are not in the queries already.
+func addPortQueries(queries []connQuery, ports []uint32) []connQuery {
+	seen := make(map[uint32]bool)
+	for _, q := range queries {
+		seen[q.LocalPort] = true
+	}
+	for _, port := range ports {
+		if !seen[port] {
+			queries = append(queries, connQuery{LocalPort: port})
+			seen[port] = true
+		}
+	}
+	return queries
+}
+
+// splitHostPort splits given address into a normalized IP and port.
+func splitHostPort(addr string) (string, uint32, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, err
+	}
+	return normalizeIP(host), uint32(port), nil
+}
+
+// joinHostPort returns the address string of given IP and port, empty if IP is empty.
+func joinHostPort(ip string, port uint32) string {
+	if ip == "" {
+		return ""
+	}
+	return net.JoinHostPort(ip, strconv.FormatUint(uint64(port), 10))
+}
+
+// normalizeIP returns the canonical form of given IP, IPv4-mapped IPv6 addresses are converted to IPv4.
+func normalizeIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	return ip.String()
+}
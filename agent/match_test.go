@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConnQueryMatch(t *testing.T) {
+	conns := []Conn{
+		{Pid: 1, Port: 3306, Status: "LISTEN", LocalIP: "0.0.0.0"},
+		{Pid: 2, Port: 51234, Status: "ESTABLISHED", LocalIP: "10.0.0.5", RemoteIP: "10.0.0.9", RemotePort: 3306},
+		{Pid: 3, Port: 51234, Status: "ESTABLISHED", LocalIP: "172.17.0.2", RemoteIP: "10.0.0.9", RemotePort: 3306},
+		{Pid: 4, Port: 51234, Status: "ESTABLISHED", LocalIP: "10.0.0.5", RemoteIP: "10.0.0.8", RemotePort: 443},
+		{Pid: 5, Port: 51300, Status: "ESTABLISHED", LocalIP: "10.0.0.5", RemoteIP: "10.0.0.100", RemotePort: 3306},
+		{Pid: 6, Port: 51400, Status: "ESTABLISHED", LocalIP: "10.0.0.5", RemoteIP: "10.0.0.8", RemotePort: 443},
+		{Pid: 7, Port: 51400, Status: "ESTABLISHED", LocalIP: "10.0.0.5", RemoteIP: "10.0.0.7", RemotePort: 443},
+	}
+	tests := []struct {
+		name         string
+		query        connQuery
+		wantPids     []int32
+		wantMismatch bool
+	}{
+		{"port only", connQuery{LocalPort: 51234}, []int32{2, 3, 4}, false},
+		{"local address", connQuery{LocalIP: "10.0.0.5", LocalPort: 51234}, []int32{2, 4}, false},
+		{"remote address", connQuery{LocalPort: 51234, RemoteIP: "10.0.0.9", RemotePort: 3306}, []int32{2, 3}, false},
+		{"local and remote addresses", connQuery{LocalIP: "10.0.0.5", LocalPort: 51234, RemoteIP: "10.0.0.9", RemotePort: 3306},
+			[]int32{2}, false},
+		{"local address behind nat", connQuery{LocalIP: "192.168.1.10", LocalPort: 51234, RemoteIP: "10.0.0.9", RemotePort: 3306},
+			[]int32{2, 3}, false},
+		{"only connection with the port", connQuery{LocalIP: "10.0.0.5", LocalPort: 51300, RemoteIP: "10.0.0.9", RemotePort: 3306},
+			[]int32{5}, true},
+		{"remote matches none of many", connQuery{LocalIP: "10.0.0.5", LocalPort: 51400, RemoteIP: "10.0.0.9", RemotePort: 3306},
+			nil, false},
+		{"listening socket", connQuery{LocalPort: 3306}, nil, false},
+		{"no connection", connQuery{LocalIP: "10.0.0.5", LocalPort: 60000}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, mismatch := tt.query.match(conns)
+			var pids []int32
+			for _, conn := range matched {
+				pids = append(pids, conn.Pid)
+			}
+			if !slices.Equal(pids, tt.wantPids) || mismatch != tt.wantMismatch {
+				t.Errorf("match(%s) = %v, %t, want %v, %t", tt.query, pids, mismatch, tt.wantPids, tt.wantMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"kimo/config"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/cenkalti/log"
+	gopsutilProcess "github.com/shirou/gopsutil/v4/process"
+)
+
+// readEnvTags reads environment variables of the process with given pid and returns
+// the ones those are in given allowlist. Other variables are never returned.
+func readEnvTags(pid int32, allowlist []string) (map[string]string, error) {
+	content, err := os.ReadFile(procPath(fmt.Sprint(pid), "environ"))
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for _, env := range bytes.Split(content, []byte{0}) {
+		key, value, ok := bytes.Cut(env, []byte("="))
+		if !ok {
+			continue
+		}
+		if slices.Contains(allowlist, string(key)) {
+			tags[string(key)] = string(value)
+		}
+	}
+	return tags, nil
+}
+
+// addMetadata sets optional metadata fields of given process those are enabled in config.
+func addMetadata(p *Process, cfg *config.ProcessConfig) {
+	if !cfg.Any() {
+		return
+	}
+
+	process, err := gopsutilProcess.NewProcess(p.Pid)
+	if err != nil {
+		log.Debugf("Error occured while finding the process %s\n", err.Error())
+		return
+	}
+
+	if cfg.User {
+		uids, err := process.Uids()
+		if err != nil || len(uids) == 0 {
+			log.Debugf("Uid not found for %d\n", p.Pid)
+		} else {
+			uid := uids[0] // real uid
+			p.UID = &uid
+		}
+		p.Username, err = process.Username()
+		if err != nil {
+			log.Debugf("Username not found for %d\n", p.Pid)
+		}
+	}
+
+	if cfg.Parent {
+		p.PPid, err = process.Ppid()
+		if err != nil {
+			log.Debugf("Ppid not found for %d\n", p.Pid)
+		} else if parent, err := gopsutilProcess.NewProcess(p.PPid); err == nil {
+			p.ParentCmdLine, err = parent.Cmdline()
+			if err != nil {
+				log.Debugf("Cmdline not found for %d\n", p.PPid)
+			}
+		}
+	}
+
+	if cfg.StartTime {
+		ms, err := process.CreateTime()
+		if err != nil {
+			log.Debugf("Create time not found for %d\n", p.Pid)
+		} else {
+			t := time.UnixMilli(ms)
+			p.StartTime = &t
+		}
+	}
+
+	if cfg.Cwd {
+		p.Cwd, err = process.Cwd()
+		if err != nil {
+			log.Debugf("Cwd not found for %d\n", p.Pid)
+		}
+	}
+
+	if cfg.Exe {
+		p.Exe, err = process.Exe()
+		if err != nil {
+			log.Debugf("Exe not found for %d\n", p.Pid)
+		}
+	}
+
+	if cfg.RSS {
+		mi, err := process.MemoryInfo()
+		if err != nil {
+			log.Debugf("Memory info not found for %d\n", p.Pid)
+		} else {
+			p.RSS = mi.RSS
+		}
+	}
+
+	if cfg.NumFDs {
+		p.NumFDs, err = process.NumFDs()
+		if err != nil {
+			log.Debugf("Fd count not found for %d\n", p.Pid)
+		}
+	}
+}
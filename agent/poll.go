@@ -39,12 +39,26 @@ func (a *Agent) pollConns(ctx context.Context) error {
 // doPoll retrieves the current network connections and updates the Agent's connection state.
 // It returns an error if fetching connections fails.
 func (a *Agent) doPoll(ctx context.Context) error {
-	gopsConns, err := getConns(ctx)
-	if err != nil {
-		return err
+	var conns []Conn
+	switch a.Config.Collector {
+	case CollectorProcfs:
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		var err error
+		conns, err = getProcfsConns(ctx, remotePortsFilter(a.Config.RemotePorts))
+		if err != nil {
+			return err
+		}
+	case CollectorGopsutil:
+		gopsConns, err := getConns(ctx)
+		if err != nil {
+			return err
+		}
+		conns = filterRemotePorts(a.ConvertConns(gopsConns), a.Config.RemotePorts)
+	default:
+		return fmt.Errorf("unknown collector: %s", a.Config.Collector)
 	}
 
-	conns := a.ConvertConns(gopsConns)
 	a.SetConns(conns)
 
 	log.Debugf("Updated connections: %d", len(conns))
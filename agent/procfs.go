@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cenkalti/log"
+)
+
+// Connection collectors
+const (
+	CollectorGopsutil = "gopsutil"
+	CollectorProcfs   = "procfs"
+)
+
+// tcpStates maps socket states in /proc/net/tcp to the names that gopsutil uses.
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// getProcfsConns reads TCP connections from /proc/net/tcp and /proc/net/tcp6.
+// Unlike gopsutil, it filters sockets with keep before resolving their pids and
+// stops walking process fds as soon as all matching sockets are found.
+func getProcfsConns(ctx context.Context, keep func(conn *Conn) bool) ([]Conn, error) {
+	inodes := make(map[uint64]*Conn)
+	for _, name := range []string{"tcp", "tcp6"} {
+		err := readProcNetTCP(procPath("net", name), keep, inodes)
+		if err != nil {
+			if name == "tcp6" && os.IsNotExist(err) { // IPv6 is disabled.
+				continue
+			}
+			return nil, err
+		}
+	}
+
+	err := resolveSocketPids(ctx, inodes)
+	if err != nil {
+		return nil, err
+	}
+
+	conns := make([]Conn, 0, len(inodes))
+	for _, conn := range inodes {
+		if conn.Pid == 0 { // owned by a process in another namespace or closed meanwhile.
+			continue
+		}
+		conns = append(conns, *conn)
+	}
+	return conns, nil
+}
+
+// readProcNetTCP parses sockets in given /proc/net/tcp{,6} file into inodes map.
+// Sockets those are not kept by given function are skipped.
+func readProcNetTCP(path string, keep func(conn *Conn) bool, inodes map[uint64]*Conn) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil || inode == 0 { // e.g. TIME_WAIT sockets are not owned by a process.
+			continue
+		}
+		localIP, localPort, err := parseProcNetAddress(fields[1])
+		if err != nil {
+			log.Debugf("Can not parse local address %s: %s\n", fields[1], err)
+			continue
+		}
+		remoteIP, remotePort, err := parseProcNetAddress(fields[2])
+		if err != nil {
+			log.Debugf("Can not parse remote address %s: %s\n", fields[2], err)
+			continue
+		}
+		conn := &Conn{
+			Port:       localPort,
+			Status:     tcpStates[fields[3]],
+			LocalIP:    localIP,
+			RemoteIP:   remoteIP,
+			RemotePort: remotePort,
+		}
+		if keep(conn) {
+			inodes[inode] = conn
+		}
+	}
+	return scanner.Err()
+}
+
+// parseProcNetAddress parses an address in /proc/net/tcp{,6} format, e.g. 0100007F:0CEA.
+// IP is written as 32 bit words in host byte order (little endian on supported platforms).
+func parseProcNetAddress(s string) (string, uint32, error) {
+	ipHex, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid address: %s", s)
+	}
+	b, err := hex.DecodeString(ipHex)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return "", 0, fmt.Errorf("invalid IP length: %s", s)
+	}
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return "", 0, err
+	}
+	return net.IP(b).String(), uint32(port), nil
+}
+
+// resolveSocketPids sets pids of given sockets by walking /proc/<pid>/fd.
+func resolveSocketPids(ctx context.Context, inodes map[uint64]*Conn) error {
+	if len(inodes) == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(procPath())
+	if err != nil {
+		return err
+	}
+
+	remaining := len(inodes)
+	for _, entry := range entries {
+		pid, err := strconv.ParseInt(entry.Name(), 10, 32)
+		if err != nil { // not a process directory.
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		fdDir := procPath(entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil { // process is gone or not permitted.
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(fdDir + "/" + fd.Name())
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(link[len("socket:["):len(link)-1], 10, 64)
+			if err != nil {
+				continue
+			}
+			if conn, ok := inodes[inode]; ok && conn.Pid == 0 {
+				conn.Pid = int32(pid)
+				remaining--
+				if remaining == 0 {
+					return nil
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// remotePortsFilter returns a function that keeps connections to one of given remote ports, all if ports are empty.
+func remotePortsFilter(remotePorts []uint32) func(conn *Conn) bool {
+	return func(conn *Conn) bool {
+		return len(remotePorts) == 0 || containsPort(remotePorts, conn.RemotePort)
+	}
+}
+
+// filterRemotePorts returns the connections those are connected to one of given remote ports, all if ports are empty.
+func filterRemotePorts(conns []Conn, remotePorts []uint32) []Conn {
+	if len(remotePorts) == 0 {
+		return conns
+	}
+	filtered := make([]Conn, 0)
+	for _, conn := range conns {
+		if containsPort(remotePorts, conn.RemotePort) {
+			filtered = append(filtered, conn)
+		}
+	}
+	return filtered
+}
+
+func containsPort(ports []uint32, port uint32) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
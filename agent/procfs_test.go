@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+
+	"kimo/config"
+)
+
+func TestParseProcNetAddress(t *testing.T) {
+	tests := []struct {
+		addr     string
+		wantIP   string
+		wantPort uint32
+		wantErr  bool
+	}{
+		{"0100007F:0CEA", "127.0.0.1", 3306, false},
+		{"00000000:0050", "0.0.0.0", 80, false},
+		{"0500000A:C822", "10.0.0.5", 51234, false},
+		{"00000000000000000000000001000000:0CEA", "::1", 3306, false},
+		{"0000000000000000FFFF00000500000A:C822", "10.0.0.5", 51234, false},
+		{"B80D0120000000000000000001000000:1F90", "2001:db8::1", 8080, false},
+		{"0100007F", "", 0, true},
+		{"0100007G:0CEA", "", 0, true},
+		{"00007F:0CEA", "", 0, true},
+		{"0100007F:FFFFF", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			ip, port, err := parseProcNetAddress(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProcNetAddress(%q) error = %v, want error %t", tt.addr, err, tt.wantErr)
+			}
+			if ip != tt.wantIP || port != tt.wantPort {
+				t.Errorf("parseProcNetAddress(%q) = %s, want %s", tt.addr, joinHostPort(ip, port), joinHostPort(tt.wantIP, tt.wantPort))
+			}
+		})
+	}
+}
+
+// benchmarkConns is the number of connections to the "database" port, opened besides the unrelated ones.
+const benchmarkConns = 200
+
+// openConns opens n loopback connections to a new listener and returns its port.
+// Connections are closed when the benchmark ends.
+func openConns(b *testing.B, n int) uint32 {
+	b.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { ln.Close() })
+	accepted := make(chan net.Conn, n)
+	go func() {
+		for i := 0; i < n; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			b.Fatal(err)
+		}
+		peer := <-accepted
+		b.Cleanup(func() {
+			conn.Close()
+			peer.Close()
+		})
+	}
+	return uint32(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// benchmarkCollector polls connections to the database port with given collector,
+// while as many unrelated connections exist on the host.
+func benchmarkCollector(b *testing.B, collector string) {
+	port := openConns(b, benchmarkConns)
+	openConns(b, benchmarkConns) // unrelated connections
+
+	cfg := config.NewConfig().Agent
+	cfg.Collector = collector
+	cfg.RemotePorts = []uint32{port}
+	a := NewAgent(&cfg)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := a.doPoll(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if n := len(a.GetConns()); n < benchmarkConns {
+		b.Fatalf("%d connections are collected, expected at least %d", n, benchmarkConns)
+	}
+}
+
+func BenchmarkProcfsCollector(b *testing.B) {
+	if runtime.GOOS != "linux" {
+		b.Skip("procfs collector is supported on Linux only")
+	}
+	benchmarkCollector(b, CollectorProcfs)
+}
+
+func BenchmarkGopsutilCollector(b *testing.B) {
+	benchmarkCollector(b, CollectorGopsutil)
+}
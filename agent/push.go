@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"kimo/auth"
+	"kimo/tlsutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/log"
+)
+
+// Snapshot is the process snapshot that is pushed to the server.
+type Snapshot struct {
+	Hostname  string     `json:"hostname"`
+	Version   string     `json:"version"`
+	IPs       []string   `json:"ips"`
+	Processes []*Process `json:"processes"`
+}
+
+// pushSnapshots periodically pushes process snapshots to the server until context is done.
+func (a *Agent) pushSnapshots(ctx context.Context) error {
+	log.Infof("Pushing snapshots to %s...\n", a.Config.Push.ServerURL)
+	token, err := auth.LoadToken(a.Config.Push.TokenFile, a.Config.Push.TokenEnv)
+	if err != nil {
+		return fmt.Errorf("can not load push token: %w", err)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if a.Config.Push.TLS.Enabled() {
+		u, err := url.Parse(a.Config.Push.ServerURL)
+		if err != nil {
+			return fmt.Errorf("invalid push server url: %w", err)
+		}
+		// Certificate files are reloaded on new connections.
+		tlsConfig, err := tlsutil.NewFiles(a.Config.Push.TLS).ClientConfig(u.Hostname())
+		if err != nil {
+			return fmt.Errorf("push tls config error: %w", err)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	ticker := time.NewTicker(a.Config.Push.Interval)
+	defer ticker.Stop()
+	for {
+		if err := a.push(ctx, client, token); err != nil {
+			log.Errorf("Push failed: %s\n", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Infoln("Pushing stopped.")
+			return nil
+		}
+	}
+}
+
+// push sends a snapshot of the processes those have connections to the server.
+func (a *Agent) push(ctx context.Context, client *http.Client, token string) error {
+	snap, err := a.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(a.Config.Push.ServerURL, "/") + "/agents/push"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	auth.SetHeader(req, token)
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("HTTP request failed: %s", response.Status)
+	}
+	log.Debugf("Pushed %d processes\n", len(snap.Processes))
+	return nil
+}
+
+// snapshot creates a snapshot of the processes those have connections.
+func (a *Agent) snapshot(ctx context.Context) (*Snapshot, error) {
+	conns := a.GetConns()
+	if a.lookups != nil { // connections are not polled in on demand mode.
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		var err error
+		conns, err = getProcfsConns(ctx, remotePortsFilter(a.Config.RemotePorts))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	snap := &Snapshot{Hostname: a.Hostname, Version: Version, IPs: localIPs(), Processes: make([]*Process, 0, len(conns))}
+	processes := make(map[int32]*Process) // process info is collected once per pid.
+	for _, conn := range conns {
+		p, ok := processes[conn.Pid]
+		if !ok {
+			var err error
+			p, err = newProcess(conn.Pid)
+			if err != nil {
+				log.Debugf("Error occured while finding the process %s\n", err.Error())
+				continue
+			}
+			a.addProcessInfo(p)
+			processes[conn.Pid] = p
+		}
+		cp := *p
+		cp.Status = conn.Status
+		cp.Port = conn.Port
+		cp.LocalAddress = joinHostPort(conn.LocalIP, conn.Port)
+		cp.RemoteAddress = joinHostPort(conn.RemoteIP, conn.RemotePort)
+		snap.Processes = append(snap.Processes, &cp)
+	}
+	return snap, nil
+}
+
+// localIPs returns the non-loopback IPs of the host.
+func localIPs() []string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Errorf("Can not get interface addresses: %s\n", err)
+		return nil
+	}
+	var ips []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok && !ipNet.IP.IsLoopback() {
+			ips = append(ips, ipNet.IP.String())
+		}
+	}
+	return ips
+}
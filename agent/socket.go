@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/cenkalti/log"
+	gopsutilProcess "github.com/shirou/gopsutil/v4/process"
+)
+
+// findSocketProcesses finds the client processes of the unix socket connections
+// those have given fds in the database server process running on this host.
+func (a *Agent) findSocketProcesses(fds []uint32) []*Process {
+	ps := make([]*Process, 0)
+	if !a.Config.UnixSocket.Enabled {
+		log.Debugln("Unix socket resolution is not enabled")
+		return ps
+	}
+
+	serverPid, err := findPidByName(a.Config.UnixSocket.ServerProcess)
+	if err != nil {
+		log.Errorf("Can not find database server process: %s\n", err)
+		return ps
+	}
+
+	for _, fd := range fds {
+		pid, err := peerPid(serverPid, int32(fd))
+		if err != nil {
+			log.Debugf("Peer of fd %d could not be found: %s\n", fd, err)
+			continue
+		}
+
+		p, err := newProcess(pid)
+		if err != nil {
+			log.Debugf("Error occured while finding the process %s\n", err.Error())
+			continue
+		}
+		p.Status = "ESTABLISHED"
+		p.Fd = int32(fd)
+
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// findPidByName returns the pid of the first process with given name.
+func findPidByName(name string) (int32, error) {
+	processes, err := gopsutilProcess.Processes()
+	if err != nil {
+		return 0, err
+	}
+	for _, process := range processes {
+		n, err := process.Name()
+		if err == nil && n == name {
+			return process.Pid, nil
+		}
+	}
+	return 0, fmt.Errorf("process %s not found", name)
+}
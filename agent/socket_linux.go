@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerPid returns the pid of the peer process of the unix socket with given fd in the process with given pid.
+// The socket is duplicated with pidfd_getfd (Linux 5.6+, requires ptrace permission on the process)
+// and the peer is read with SO_PEERCRED.
+func peerPid(pid int32, fd int32) (int32, error) {
+	pidfd, err := unix.PidfdOpen(int(pid), 0)
+	if err != nil {
+		return 0, fmt.Errorf("pidfd_open: %w", err)
+	}
+	defer unix.Close(pidfd)
+
+	sfd, err := unix.PidfdGetfd(pidfd, int(fd), 0)
+	if err != nil {
+		return 0, fmt.Errorf("pidfd_getfd: %w", err)
+	}
+	defer unix.Close(sfd)
+
+	cred, err := unix.GetsockoptUcred(sfd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+	if err != nil {
+		return 0, fmt.Errorf("SO_PEERCRED: %w", err)
+	}
+	return cred.Pid, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package agent
+
+import "errors"
+
+// peerPid is only supported on linux.
+func peerPid(pid int32, fd int32) (int32, error) {
+	return 0, errors.New("unix socket resolution is supported on linux only")
+}
@@ -0,0 +1,141 @@
+// Package auth implements shared bearer token authentication of kimo HTTP APIs.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"kimo/config"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/cenkalti/log"
+)
+
+// QueryParam is the query parameter that the token can be given with, for browsers (e.g. /?access_token=...).
+const QueryParam = "access_token"
+
+// Tokens holds the tokens those are accepted by an HTTP API. Multiple tokens are allowed for rotation.
+type Tokens struct {
+	enabled bool
+	hashes  [][sha256.Size]byte // tokens are compared by their hashes so that comparison does not leak their lengths.
+}
+
+// NewTokens loads tokens from the files and environment variables in given config.
+// Authentication is enabled if any token source is configured, even if none of them could be loaded;
+// in that case all requests are rejected.
+func NewTokens(cfg config.AuthConfig) *Tokens {
+	t := &Tokens{enabled: len(cfg.TokenFiles) > 0 || len(cfg.TokenEnvs) > 0}
+	for _, file := range cfg.TokenFiles {
+		token, err := LoadToken(file, "")
+		if err != nil {
+			log.Errorf("Can not load token: %s\n", err)
+			continue
+		}
+		t.hashes = append(t.hashes, sha256.Sum256([]byte(token)))
+	}
+	for _, env := range cfg.TokenEnvs {
+		token, err := LoadToken("", env)
+		if err != nil {
+			log.Errorf("Can not load token: %s\n", err)
+			continue
+		}
+		t.hashes = append(t.hashes, sha256.Sum256([]byte(token)))
+	}
+	if t.enabled && len(t.hashes) == 0 {
+		log.Errorln("No token could be loaded, all requests will be rejected")
+	}
+	return t
+}
+
+// LoadToken reads a token from given file or environment variable.
+func LoadToken(file, env string) (string, error) {
+	var token string
+	switch {
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		token = strings.TrimSpace(string(b))
+	case env != "":
+		token = strings.TrimSpace(os.Getenv(env))
+	default:
+		return "", nil
+	}
+	if token == "" {
+		return "", errors.New("token is empty")
+	}
+	return token, nil
+}
+
+// Enabled returns true if requests are authenticated.
+func (t *Tokens) Enabled() bool {
+	return t.enabled
+}
+
+// Valid returns true if given token is one of the accepted tokens.
+// All tokens are compared in constant time regardless of a match.
+func (t *Tokens) Valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	h := sha256.Sum256([]byte(token))
+	valid := 0
+	for i := range t.hashes {
+		valid |= subtle.ConstantTimeCompare(h[:], t.hashes[i][:])
+	}
+	return valid == 1
+}
+
+// ValidRequest returns true if the request has one of the accepted tokens.
+func (t *Tokens) ValidRequest(req *http.Request) bool {
+	return t.Valid(requestToken(req))
+}
+
+// requestToken returns the bearer token of the request from Authorization header or the query param.
+func requestToken(req *http.Request) string {
+	if h := req.Header.Get("Authorization"); h != "" {
+		return BearerToken(h)
+	}
+	return req.URL.Query().Get(QueryParam)
+}
+
+// BearerToken returns the token in given Authorization header value, empty if it is not a bearer token.
+func BearerToken(h string) string {
+	scheme, token, ok := strings.Cut(h, " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// Handler returns a handler that serves requests with valid tokens by next and rejects others.
+func (t *Tokens) Handler(next http.Handler) http.Handler {
+	if !t.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !t.Valid(requestToken(req)) {
+			log.Debugf("Unauthorized request to %s from %s\n", req.URL.Path, req.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kimo"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// HandlerFunc is the same as Handler for handler functions.
+func (t *Tokens) HandlerFunc(next http.HandlerFunc) http.Handler {
+	return t.Handler(next)
+}
+
+// SetHeader sets Authorization header of given request with the token, if it is not empty.
+func SetHeader(req *http.Request, token string) {
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+}
@@ -17,28 +17,189 @@ type Config struct {
 
 // AgentConfig represents the agent section configuration
 type AgentConfig struct {
-	ListenAddress string        `yaml:"listen_address"`
-	PollInterval  time.Duration `yaml:"poll_interval"`
+	ListenAddress string `yaml:"listen_address"`
+	// gRPC service listens this address, disabled if empty.
+	GRPCListenAddress string           `yaml:"grpc_listen_address"`
+	PollInterval      time.Duration    `yaml:"poll_interval"`
+	Collector         string           `yaml:"collector"`    // "gopsutil" or "procfs"
+	RemotePorts       []uint32         `yaml:"remote_ports"` // only connections to these ports are collected, all if empty
+	OnDemand          bool             `yaml:"on_demand"`    // look up requested ports on demand instead of polling
+	CacheTTL          time.Duration    `yaml:"cache_ttl"`    // duration that on demand lookup results are reused
+	UnixSocket        UnixSocketConfig `yaml:"unix_socket"`
+	Container         ContainerConfig  `yaml:"container"`
+	Process           ProcessConfig    `yaml:"process"`
+	EnvTags           []string         `yaml:"env_tags"` // allowlist of environment variables returned as process tags
+	TLS               TLSConfig        `yaml:"tls"`
+	Auth              AuthConfig       `yaml:"auth"`
+	Push              PushConfig       `yaml:"push"`
+}
+
+// PushConfig holds configuration of push mode, where the agent sends its process snapshots to the server
+// instead of the server connecting to the agent.
+type PushConfig struct {
+	ServerURL string        `yaml:"server_url"` // e.g. https://kimo-server:3322, push mode is disabled if empty
+	Interval  time.Duration `yaml:"interval"`
+	TLS       TLSConfig     `yaml:"tls"` // CA to verify the server and optional client certificate
+	// Bearer token sent to the server, read from the file or the environment variable.
+	TokenFile string `yaml:"token_file"`
+	TokenEnv  string `yaml:"token_env"`
+}
+
+// AuthConfig holds the sources of bearer tokens those are accepted by an HTTP API.
+// Authentication is disabled if no source is configured.
+type AuthConfig struct {
+	TokenFiles []string `yaml:"token_files"` // files those contain a token each
+	TokenEnvs  []string `yaml:"token_envs"`  // environment variables those contain a token each
+}
+
+// TLSConfig holds paths of TLS files. Files are reloaded when they change.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// Agent verifies client certificates with this CA, server verifies agent certificates with it.
+	CAFile string `yaml:"ca_file"`
+	// Name in agent certificates to be verified by the server, agents are connected by IP.
+	ServerName string `yaml:"server_name"`
+}
+
+// Enabled returns true if any of the TLS files is set.
+func (tc TLSConfig) Enabled() bool {
+	return tc.CertFile != "" || tc.KeyFile != "" || tc.CAFile != ""
+}
+
+// ProcessConfig holds which optional process metadata are collected by the agent.
+type ProcessConfig struct {
+	User      bool `yaml:"user"`       // uid and username
+	Parent    bool `yaml:"parent"`     // parent pid and parent cmdline
+	StartTime bool `yaml:"start_time"` // process start time
+	Cwd       bool `yaml:"cwd"`        // current working directory
+	Exe       bool `yaml:"exe"`        // executable path
+	RSS       bool `yaml:"rss"`        // resident set size in bytes
+	NumFDs    bool `yaml:"num_fds"`    // open file descriptor count
+}
+
+// Any returns true if any of the optional metadata is enabled.
+func (pc ProcessConfig) Any() bool {
+	return pc.User || pc.Parent || pc.StartTime || pc.Cwd || pc.Exe || pc.RSS || pc.NumFDs
+}
+
+// UnixSocketConfig holds configuration for resolving unix socket connections of the database server
+// when the agent runs on the database host.
+type UnixSocketConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	ServerProcess string `yaml:"server_process"` // name of the database server process, e.g. mysqld or mariadbd
 }
 
 // ServerConfig represents the server section configuration
 type ServerConfig struct {
-	ListenAddress string        `yaml:"listen_address"`
-	PollInterval  time.Duration `yaml:"poll_interval"`
-	MySQL         MySQLConfig   `yaml:"mysql"`
-	Agent         AgentInfo     `yaml:"agent"`
-	TCPProxy      TCPProxy      `yaml:"tcpproxy"`
-	Metric        Metric        `yaml:"metric"`
+	ListenAddress string            `yaml:"listen_address"`
+	PollInterval  time.Duration     `yaml:"poll_interval"`
+	MySQL         MySQLTargets      `yaml:"mysql"`
+	PostgreSQL    PostgreSQLTargets `yaml:"postgresql"`
+	Agent         AgentInfo         `yaml:"agent"`
+	TCPProxy      TCPProxy          `yaml:"tcpproxy"`
+	Metric        Metric            `yaml:"metric"`
+	Kill          Kill              `yaml:"kill"`
+	Auth          AuthConfig        `yaml:"auth"` // protects /procs, /locks, /metrics, /agents, /agents/push and the UI
+	// Serve over TLS if cert_file and key_file are set. Client certificates are optional and verified with ca_file.
+	TLS TLSConfig `yaml:"tls"`
 }
 
 // MySQLConfig holds MySQL specific configuration
 type MySQLConfig struct {
-	DSN string `yaml:"dsn"`
+	Name string `yaml:"name"`
+	DSN  string `yaml:"dsn"`
+	// Read connections from performance_schema.threads instead of information_schema.PROCESSLIST.
+	PerformanceSchema bool `yaml:"performance_schema"`
+	// Connection pool settings. Zero values mean defaults (1 open, 1 idle conn, 5m lifetime).
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// MySQLTargets holds the MySQL servers to be monitored.
+type MySQLTargets []MySQLConfig
+
+// UnmarshalYAML accepts either a single mysql section or a list of named targets.
+func (t *MySQLTargets) UnmarshalYAML(value *yaml.Node) error {
+	mcs, err := decodeTargets[MySQLConfig](value)
+	if err != nil {
+		return err
+	}
+	*t = mcs
+	return nil
+}
+
+// PostgreSQLConfig holds PostgreSQL specific configuration
+type PostgreSQLConfig struct {
+	Name string `yaml:"name"`
+	DSN  string `yaml:"dsn"`
+	// Connection pool settings. Zero values mean defaults (1 open, 1 idle conn, 5m lifetime).
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// PostgreSQLTargets holds the PostgreSQL servers to be monitored.
+type PostgreSQLTargets []PostgreSQLConfig
+
+// UnmarshalYAML accepts either a single postgresql section or a list of named targets.
+func (t *PostgreSQLTargets) UnmarshalYAML(value *yaml.Node) error {
+	pcs, err := decodeTargets[PostgreSQLConfig](value)
+	if err != nil {
+		return err
+	}
+	*t = pcs
+	return nil
+}
+
+// decodeTargets decodes a single target mapping or a list of targets.
+func decodeTargets[T any](value *yaml.Node) ([]T, error) {
+	if value.Kind == yaml.MappingNode {
+		var c T
+		if err := value.Decode(&c); err != nil {
+			return nil, err
+		}
+		return []T{c}, nil
+	}
+	var cs []T
+	if err := value.Decode(&cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
 }
 
 // AgentInfo holds agent-related configuration within server section
 type AgentInfo struct {
-	Port uint32 `yaml:"port"`
+	Port uint32    `yaml:"port"`
+	TLS  TLSConfig `yaml:"tls"` // client certificate and CA to connect agents over TLS
+	// "http", "grpc" or "auto". In auto mode agent capabilities are asked first and
+	// gRPC is used for the agents those support it, so that agents can be upgraded gradually.
+	Protocol string `yaml:"protocol"`
+	GRPCPort uint32 `yaml:"grpc_port"` // used in grpc mode, agents tell their port in auto mode
+	// Bearer token sent to agents, read from the file or the environment variable.
+	TokenFile string `yaml:"token_file"`
+	TokenEnv  string `yaml:"token_env"`
+
+	Push AgentPushConfig `yaml:"push"` // agents pushing their snapshots
+
+	MaxConcurrency int           `yaml:"max_concurrency"` // maximum number of agents those are requested at the same time
+	Timeout        time.Duration `yaml:"timeout"`         // timeout of a single request to an agent
+	Retries        int           `yaml:"retries"`         // number of retries after a failed request
+	RetryBackoff   time.Duration `yaml:"retry_backoff"`   // doubled on every retry, with jitter
+	// Agents are skipped for breaker_cooldown after breaker_failures consecutive failed polls. Disabled if zero.
+	BreakerFailures int           `yaml:"breaker_failures"`
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"`
+	// Processes found by agents are reused for connections with the same id until they are older than this. Disabled if zero.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// AgentPushConfig holds server side configuration of agents pushing their snapshots.
+// Push requires auth tokens or a CA to verify agent client certificates, since pushed snapshots are trusted.
+type AgentPushConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Snapshots pushed by agents are used instead of pulling them until they are older than this.
+	TTL time.Duration `yaml:"ttl"`
 }
 
 // TCPProxy holds TCP proxy configuration
@@ -49,6 +210,28 @@ type TCPProxy struct {
 // Metric holds metric-related configuration
 type Metric struct {
 	CmdlinePatterns []string `yaml:"cmdline_patterns"`
+	TagLabels       []string `yaml:"tag_labels"` // query tag keys those are exposed as labels of connection metric
+}
+
+// Kill holds configuration of the kill endpoint
+type Kill struct {
+	Users []KillUser `yaml:"users"` // users those are allowed to kill, disabled if empty. Requires TLS.
+}
+
+// KillUser is a user of the kill endpoint, authenticated with its own bearer token.
+type KillUser struct {
+	Name string `yaml:"name"` // logged with the killed processes
+	// Bearer token of the user, read from the file or the environment variable.
+	TokenFile string `yaml:"token_file"`
+	TokenEnv  string `yaml:"token_env"`
+}
+
+// ContainerConfig holds configuration for attributing processes to containers and kubernetes pods.
+type ContainerConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	DockerSocket string `yaml:"docker_socket"` // docker compatible API socket to resolve container names, e.g. /var/run/docker.sock
+	CRIOSocket   string `yaml:"crio_socket"`   // cri-o socket to resolve container names, e.g. /var/run/crio/crio.sock
+	MappingFile  string `yaml:"mapping_file"`  // static container id and pod uid to name mapping
 }
 
 // NewConfig creates and returns a new Config.
@@ -74,17 +257,36 @@ func (c *Config) LoadConfig(name string) error {
 var defaultConfig = Config{
 	Debug: true,
 	Agent: AgentConfig{
-		ListenAddress: "0.0.0.0:3333",
+		ListenAddress: ":3333", // listens both IPv4 and IPv6
 		PollInterval:  10 * time.Second,
+		Collector:     "gopsutil",
+		CacheTTL:      2 * time.Second,
+		Push: PushConfig{
+			Interval: 10 * time.Second,
+		},
+		UnixSocket: UnixSocketConfig{
+			ServerProcess: "mysqld",
+		},
 	},
 	Server: ServerConfig{
 		ListenAddress: "0.0.0.0:3322",
 		PollInterval:  12 * time.Second,
-		MySQL: MySQLConfig{
-			DSN: "",
-		},
+		MySQL:         MySQLTargets{},
 		Agent: AgentInfo{
-			Port: 3333,
+			Port:     3333,
+			Protocol: "http",
+			GRPCPort: 3334,
+			Push: AgentPushConfig{
+				TTL: 30 * time.Second,
+			},
+
+			MaxConcurrency:  32,
+			Timeout:         5 * time.Second,
+			Retries:         2,
+			RetryBackoff:    200 * time.Millisecond,
+			BreakerFailures: 3,
+			BreakerCooldown: time.Minute,
+			CacheTTL:        5 * time.Minute,
 		},
 	},
 }
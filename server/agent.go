@@ -1,11 +1,19 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"kimo/auth"
+	"kimo/tlsutil"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cenkalti/log"
 )
@@ -15,8 +23,40 @@ type AgentProcess struct {
 	ConnectionStatus string `json:"status"`
 	Pid              uint32 `json:"pid"`
 	Port             uint32 `json:"port"` // process uses this port to communicate with MySQL.
+	Fd               int32  `json:"fd"`   // fd of the unix socket connection in the database server process.
+	LocalAddress     string `json:"local_address"`
+	RemoteAddress    string `json:"remote_address"`
+	Candidates       int    `json:"candidates"`      // number of connections matching the query if agent could not decide.
+	RemoteMismatch   bool   `json:"remote_mismatch"` // connection is matched by the port only, RemoteAddress is not the server.
 	Name             string `json:"name"`
 	Cmdline          string `json:"cmdline"`
+
+	ContainerID      string `json:"container_id"`
+	ContainerRuntime string `json:"container_runtime"`
+	ContainerName    string `json:"container_name"`
+	PodUID           string `json:"pod_uid"`
+	PodName          string `json:"pod_name"`
+	PodNamespace     string `json:"pod_namespace"`
+
+	UID           *uint32    `json:"uid"`
+	Username      string     `json:"username"`
+	PPid          int32      `json:"ppid"`
+	ParentCmdline string     `json:"parent_cmdline"`
+	StartTime     *time.Time `json:"start_time"`
+	Cwd           string     `json:"cwd"`
+	Exe           string     `json:"exe"`
+	RSS           uint64     `json:"rss"`
+	NumFDs        int32      `json:"num_fds"`
+
+	Tags map[string]string `json:"tags"` // allowlisted environment variables of the process
+}
+
+// matches returns true if the process has the connection with given local port or unix socket fd.
+func (ap *AgentProcess) matches(port uint32, fd int32) bool {
+	if fd != 0 {
+		return ap.Fd == fd
+	}
+	return ap.Fd == 0 && ap.Port == port
 }
 
 // EnhancedAgentProcess represents process info along with agent's and connection's properties (error, hostname etc.)
@@ -39,6 +79,7 @@ func (eap *EnhancedAgentProcess) Host() string {
 type AgentResponse struct {
 	err      error
 	hostname string
+	version  string // version of kimo-agent, empty for older agents
 	ip       string
 
 	Processes []*AgentProcess
@@ -46,7 +87,10 @@ type AgentResponse struct {
 
 // AgentClient represents an agent client to fetch get process from a kimo-agent
 type AgentClient struct {
-	Address IPPort // kimo-agent listens this address
+	Address IPPort         // kimo-agent listens this address
+	TLS     *tlsutil.Files // nil if agents are connected over plain HTTP
+	Token   string         // bearer token, empty if agents do not require authentication
+	Client  *http.Client   // shared client to keep connections alive, a new one is created per request if nil
 }
 
 // NewAgentClient creates and returns a new AgentClient.
@@ -54,15 +98,185 @@ func NewAgentClient(address IPPort) *AgentClient {
 	return &AgentClient{Address: address}
 }
 
-// Get gets process info from kimo agent.
-func (ac *AgentClient) Get(ctx context.Context, ports []uint32) *AgentResponse {
-	url := fmt.Sprintf("http://%s:%d/proc?ports=%s", ac.Address.IP, ac.Address.Port, createPortsParam(ports))
+// AgentConn is a connection to be found by an agent.
+type AgentConn struct {
+	Local  IPPort // client address
+	Remote IPPort // address that the client is connected to, zero value if unknown.
+}
+
+// baseURL returns the URL of the agent with scheme.
+func (ac *AgentClient) baseURL() string {
+	if ac.TLS != nil {
+		return "https://" + ac.Address.String()
+	}
+	return "http://" + ac.Address.String()
+}
+
+// newAgentHTTPClient returns a client whose connections to agents are kept alive between polls.
+// TLS configuration is taken from files on every new connection, so reloaded files are used.
+func newAgentHTTPClient(files *tlsutil.Files) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 2
+	if files != nil {
+		transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig, err := files.ClientConfig(host)
+			if err != nil {
+				return nil, err
+			}
+			d := &tls.Dialer{Config: tlsConfig}
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// httpClient returns a client that connects the agent over TLS if it is configured.
+func (ac *AgentClient) httpClient() (*http.Client, error) {
+	if ac.Client != nil {
+		return ac.Client, nil
+	}
+	if ac.TLS == nil {
+		return &http.Client{}, nil
+	}
+	tlsConfig, err := ac.TLS.ClientConfig(ac.Address.IP)
+	if err != nil {
+		return nil, err
+	}
+	// Keep-alives are disabled since a new transport is created for every request.
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}}, nil
+}
+
+// httpStatusError is returned if the agent responds with an unexpected HTTP status.
+type httpStatusError struct {
+	code   int
+	status string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed: %s", e.status)
+}
+
+// errLookupNotSupported is returned if the agent is an older version without lookup endpoint.
+var errLookupNotSupported = errors.New("lookup endpoint is not supported by agent")
+
+// agentLookupConn, agentLookupResult and agentLookupResponse are the lookup endpoint types of kimo-agent.
+type agentLookupConn struct {
+	LocalIP   string `json:"local_ip,omitempty"`
+	LocalPort uint32 `json:"local_port"`
+	Remote    string `json:"remote,omitempty"`
+}
+
+type agentLookupResult struct {
+	Result    string          `json:"result"`
+	Processes []*AgentProcess `json:"processes"`
+}
+
+type agentLookupResponse struct {
+	Results []*agentLookupResult `json:"results"`
+}
+
+// Get gets process info from kimo agent for given connections and unix socket fds.
+// Older agents without lookup endpoint are asked with query params.
+func (ac *AgentClient) Get(ctx context.Context, conns []AgentConn, fds []int32) *AgentResponse {
+	ar := ac.Lookup(ctx, conns, fds)
+	if errors.Is(ar.err, errLookupNotSupported) {
+		log.Debugf("%s, falling back to /proc\n", ar.err)
+		return ac.GetProc(ctx, conns, fds)
+	}
+	return ar
+}
+
+// Lookup gets process info from kimo agent for given connections and unix socket fds in a single POST request.
+func (ac *AgentClient) Lookup(ctx context.Context, conns []AgentConn, fds []int32) *AgentResponse {
+	var body struct {
+		Conns []agentLookupConn `json:"conns"`
+		Fds   []int32           `json:"fds,omitempty"`
+	}
+	body.Conns = make([]agentLookupConn, len(conns))
+	for i, conn := range conns {
+		body.Conns[i] = agentLookupConn{LocalIP: conn.Local.IP, LocalPort: conn.Local.Port}
+		if conn.Remote.IP != "" {
+			body.Conns[i].Remote = conn.Remote.String()
+		}
+	}
+	body.Fds = fds
+	b, err := json.Marshal(body)
+	if err != nil {
+		return &AgentResponse{ip: ac.Address.IP, err: err}
+	}
+
+	url := fmt.Sprintf("%s/v1/lookup", ac.baseURL())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return &AgentResponse{ip: ac.Address.IP, err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	auth.SetHeader(req, ac.Token)
+	client, err := ac.httpClient()
+	if err != nil {
+		return &AgentResponse{ip: ac.Address.IP, err: err}
+	}
+	log.Debugf("Requesting to %s for %d conns and %d fds\n", url, len(conns), len(fds))
+	response, err := client.Do(req)
+	if err != nil {
+		return &AgentResponse{ip: ac.Address.IP, err: err}
+	}
+
+	defer response.Body.Close()
+	hostname := response.Header.Get("X-Kimo-Hostname")
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusMethodNotAllowed {
+		return &AgentResponse{ip: ac.Address.IP, err: errLookupNotSupported, hostname: hostname}
+	}
+	if response.StatusCode != 200 {
+		return &AgentResponse{
+			ip:       ac.Address.IP,
+			err:      &httpStatusError{response.StatusCode, response.Status},
+			hostname: hostname}
+	}
+
+	var r agentLookupResponse
+	err = json.NewDecoder(response.Body).Decode(&r)
+	if err != nil {
+		log.Errorln(err.Error())
+		return &AgentResponse{ip: ac.Address.IP, err: err, hostname: hostname}
+	}
+
+	var aps []*AgentProcess
+	for _, result := range r.Results {
+		aps = append(aps, result.Processes...)
+	}
+	version := response.Header.Get("X-Kimo-Version")
+	return &AgentResponse{ip: ac.Address.IP, hostname: hostname, version: version, Processes: aps}
+}
+
+// GetProc gets process info from kimo agent for given connections and unix socket fds with query params.
+// Ports are sent too for agents those do not support matching connections by addresses.
+func (ac *AgentClient) GetProc(ctx context.Context, conns []AgentConn, fds []int32) *AgentResponse {
+	ports := make([]uint32, len(conns))
+	for i, conn := range conns {
+		ports[i] = conn.Local.Port
+	}
+	url := fmt.Sprintf("%s/proc?ports=%s", ac.baseURL(), createNumbersParam(ports))
+	if len(conns) > 0 {
+		url += "&conns=" + createConnsParam(conns)
+	}
+	if len(fds) > 0 {
+		url += "&fds=" + createNumbersParam(fds)
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 
 	if err != nil {
 		return &AgentResponse{ip: ac.Address.IP, err: err}
 	}
-	client := &http.Client{}
+	auth.SetHeader(req, ac.Token)
+	client, err := ac.httpClient()
+	if err != nil {
+		return &AgentResponse{ip: ac.Address.IP, err: err}
+	}
 	log.Debugf("Requesting to %s\n", url)
 	response, err := client.Do(req)
 	if err != nil {
@@ -71,10 +285,15 @@ func (ac *AgentClient) Get(ctx context.Context, ports []uint32) *AgentResponse {
 
 	defer response.Body.Close()
 	hostname := response.Header.Get("X-Kimo-Hostname")
+	version := response.Header.Get("X-Kimo-Version")
+	if response.StatusCode == http.StatusNotFound {
+		// agent could not find any of the connections, it is a valid answer.
+		return &AgentResponse{ip: ac.Address.IP, hostname: hostname, version: version}
+	}
 	if response.StatusCode != 200 {
 		return &AgentResponse{
 			ip:       ac.Address.IP,
-			err:      fmt.Errorf("HTTP request failed: %s", response.Status),
+			err:      &httpStatusError{response.StatusCode, response.Status},
 			hostname: hostname}
 	}
 
@@ -88,21 +307,34 @@ func (ac *AgentClient) Get(ctx context.Context, ports []uint32) *AgentResponse {
 		return &AgentResponse{ip: ac.Address.IP, err: err, hostname: hostname}
 	}
 
-	return &AgentResponse{ip: ac.Address.IP, hostname: hostname, Processes: r.Processes}
+	return &AgentResponse{ip: ac.Address.IP, hostname: hostname, version: version, Processes: r.Processes}
+
+}
 
+// createNumbersParam creates comma seperated param from given slice of numbers (e.g. ports).
+func createNumbersParam[T uint32 | int32](numbers []T) string {
+	s := make([]string, len(numbers))
+	for i, n := range numbers {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, ",")
 }
 
-// createPortsParam creates comma seperated ports param from given slice of port numbers.
-func createPortsParam(ports []uint32) string {
-	numbers := make([]string, len(ports))
-	for i, port := range ports {
-		numbers[i] = fmt.Sprint(port)
+// createConnsParam creates comma seperated "local-remote" addresses param from given connections.
+func createConnsParam(conns []AgentConn) string {
+	s := make([]string, len(conns))
+	for i, conn := range conns {
+		s[i] = conn.Local.String()
+		if conn.Remote.IP != "" {
+			s[i] += "-" + conn.Remote.String()
+		}
 	}
-	return strings.Join(numbers, ",")
+	return url.QueryEscape(strings.Join(s, ","))
 }
 
-// findProcess finds EnhancedAgentProcess for given port from agent responses.
-func findProcess(addr IPPort, ars []*AgentResponse) *EnhancedAgentProcess {
+// findProcess finds EnhancedAgentProcess for given address (or unix socket fd) from agent responses.
+// server is the address that the client is connected to, it is used to choose between candidates.
+func findProcess(addr IPPort, server IPPort, fd int32, ars []*AgentResponse) *EnhancedAgentProcess {
 	for _, ar := range ars {
 		if addr.IP == ar.ip {
 			eap := &EnhancedAgentProcess{ // kimo-agent returns response
@@ -110,14 +342,47 @@ func findProcess(addr IPPort, ars []*AgentResponse) *EnhancedAgentProcess {
 				ip:       ar.ip,
 				err:      ar.err,
 			}
+			var candidates []*AgentProcess
 			for _, ap := range ar.Processes {
-				if addr.Port == ap.Port { // kimo-agent returns response with process
-					eap.AgentProcess = *ap
-					break
+				if ap.matches(addr.Port, fd) { // kimo-agent returns response with process
+					candidates = append(candidates, ap)
 				}
 			}
+			candidates = narrowCandidates(candidates, server)
+			if len(candidates) > 0 {
+				eap.AgentProcess = *candidates[0]
+			}
+			if len(candidates) > 1 {
+				eap.err = ambiguousError(candidates)
+			}
 			return eap
 		}
 	}
 	return nil
 }
+
+// narrowCandidates returns the candidates those are connected to given server address if there are any.
+func narrowCandidates(candidates []*AgentProcess, server IPPort) []*AgentProcess {
+	if len(candidates) < 2 || server.IP == "" {
+		return candidates
+	}
+	var narrowed []*AgentProcess
+	for _, ap := range candidates {
+		if ap.RemoteAddress == server.String() {
+			narrowed = append(narrowed, ap)
+		}
+	}
+	if len(narrowed) == 0 {
+		return candidates
+	}
+	return narrowed
+}
+
+// ambiguousError returns an error describing the processes those have a matching connection.
+func ambiguousError(candidates []*AgentProcess) error {
+	s := make([]string, len(candidates))
+	for i, ap := range candidates {
+		s[i] = fmt.Sprintf("pid %d (%s -> %s)", ap.Pid, ap.LocalAddress, ap.RemoteAddress)
+	}
+	return fmt.Errorf("ambiguous connection, %d processes match: %s", len(candidates), strings.Join(s, ", "))
+}
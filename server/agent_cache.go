@@ -0,0 +1,107 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// agentCacheKey identifies a client connection of a target by its address on the agent.
+type agentCacheKey struct {
+	target string
+	addr   IPPort
+}
+
+type agentCacheEntry struct {
+	id      int32 // connection id that the process is found for
+	process EnhancedAgentProcess
+	expires time.Time
+}
+
+// agentCache keeps the processes found by agents across polls, so that long-lived connections
+// (e.g. pooled ones) are not asked to agents again while the database reports the same connection id.
+type agentCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[agentCacheKey]*agentCacheEntry
+}
+
+func newAgentCache(ttl time.Duration) *agentCache {
+	return &agentCache{
+		ttl:     ttl,
+		entries: make(map[agentCacheKey]*agentCacheEntry),
+	}
+}
+
+// Get returns the cached process of the connection with given id, nil if it is not cached.
+// The entry is removed if the address is now used by another connection.
+func (c *agentCache) Get(target string, addr IPPort, id int32) *EnhancedAgentProcess {
+	key := agentCacheKey{target, addr}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if e.id != id || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	eap := e.process
+	return &eap
+}
+
+// Set caches the process of the connection with given id.
+func (c *agentCache) Set(target string, addr IPPort, id int32, eap *EnhancedAgentProcess) {
+	c.mu.Lock()
+	c.entries[agentCacheKey{target, addr}] = &agentCacheEntry{id: id, process: *eap, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+}
+
+// Prune removes expired entries, e.g. of the connections those are closed.
+func (c *agentCache) Prune() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// addCachedProcesses sets processes of raw processes those are found in the cache, returns the number of them.
+func (f *Fetcher) addCachedProcesses(rps []*RawProcess) int {
+	if f.cache == nil {
+		return 0
+	}
+	f.cache.Prune()
+	n := 0
+	for _, rp := range rps {
+		ir, ok := rp.Row.(IDRow)
+		if !ok || rp.IsUnixSocket() || rp.unresolvedHop != "" {
+			continue
+		}
+		if eap := f.cache.Get(rp.Target, rp.AgentAddress(), ir.ConnectionID()); eap != nil {
+			rp.Process = eap
+			rp.cached = true
+			n++
+		}
+	}
+	return n
+}
+
+// cacheAgentProcesses caches the processes those are found by agents without any error.
+// Ambiguous and not found connections are asked again in the next poll.
+func (f *Fetcher) cacheAgentProcesses(rps []*RawProcess) {
+	if f.cache == nil {
+		return
+	}
+	for _, rp := range rps {
+		ir, ok := rp.Row.(IDRow)
+		if !ok || rp.cached || rp.IsUnixSocket() || rp.Process == nil || rp.Process.err != nil || rp.Process.Pid == 0 {
+			continue
+		}
+		f.cache.Set(rp.Target, rp.AgentAddress(), ir.ConnectionID(), rp.Process)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgentCache(t *testing.T) {
+	addr := IPPort{IP: "10.0.0.5", Port: 51234}
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		target string
+		addr   IPPort
+		id     int32
+		want   bool
+	}{
+		{"same connection", time.Minute, "db1", addr, 42, true},
+		{"new connection id", time.Minute, "db1", addr, 43, false},
+		{"another target", time.Minute, "db2", addr, 42, false},
+		{"another address", time.Minute, "db1", IPPort{IP: "10.0.0.5", Port: 51235}, 42, false},
+		{"expired", -time.Second, "db1", addr, 42, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newAgentCache(tt.ttl)
+			c.Set("db1", addr, 42, &EnhancedAgentProcess{AgentProcess: AgentProcess{Pid: 1000, Name: "app"}})
+			eap := c.Get(tt.target, tt.addr, tt.id)
+			if found := eap != nil; found != tt.want {
+				t.Fatalf("Get() = %v, want found %t", eap, tt.want)
+			}
+			if eap != nil && eap.Pid != 1000 {
+				t.Errorf("Get() pid = %d, want 1000", eap.Pid)
+			}
+		})
+	}
+}
+
+func TestAgentCacheInvalidation(t *testing.T) {
+	addr := IPPort{IP: "10.0.0.5", Port: 51234}
+	c := newAgentCache(time.Minute)
+	c.Set("db1", addr, 42, &EnhancedAgentProcess{AgentProcess: AgentProcess{Pid: 1000}})
+
+	// the client port is reused by a new connection, the entry of the old one must be dropped.
+	if eap := c.Get("db1", addr, 43); eap != nil {
+		t.Fatalf("Get() with new connection id = %v, want nil", eap)
+	}
+	if eap := c.Get("db1", addr, 42); eap != nil {
+		t.Errorf("Get() after invalidation = %v, want nil", eap)
+	}
+	if n := len(c.entries); n != 0 {
+		t.Errorf("cache has %d entries after invalidation, want 0", n)
+	}
+}
@@ -0,0 +1,489 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kimo/agentpb"
+	"kimo/auth"
+	"kimo/tlsutil"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Agent protocols
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+	ProtocolAuto = "auto" // gRPC for the agents those support it, HTTP for others.
+)
+
+// infoTTL is the duration that capabilities of an agent are remembered.
+const infoTTL = 10 * time.Minute
+
+// grpcIdleTimeout is the duration after which gRPC connections of agents those are not asked anymore are closed.
+const grpcIdleTimeout = 10 * time.Minute
+
+// GRPCAgentClient represents an agent client to get processes from a kimo-agent over gRPC.
+type GRPCAgentClient struct {
+	Address IPPort
+	Token   string // bearer token, empty if agents do not require authentication
+
+	conn   *grpc.ClientConn
+	stream *lookupStream // nil if lookups are sent with unary Lookup calls
+}
+
+// Info gets the hostname and capabilities of the agent.
+func (gc *GRPCAgentClient) Info(ctx context.Context) (*agentInfo, error) {
+	resp, err := agentpb.NewAgentClient(gc.conn).Info(withToken(ctx, gc.Token), &agentpb.InfoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return &agentInfo{
+		Hostname:     resp.Hostname,
+		Version:      resp.Version,
+		Capabilities: resp.Capabilities,
+		GRPCPort:     resp.GrpcPort,
+	}, nil
+}
+
+// Get gets process info from kimo agent for given connections and unix socket fds.
+func (gc *GRPCAgentClient) Get(ctx context.Context, conns []AgentConn, fds []int32) *AgentResponse {
+	req := &agentpb.LookupRequest{Conns: make([]*agentpb.Conn, len(conns)), Fds: fds}
+	for i, conn := range conns {
+		req.Conns[i] = &agentpb.Conn{LocalIp: conn.Local.IP, LocalPort: conn.Local.Port}
+		if conn.Remote.IP != "" {
+			req.Conns[i].Remote = conn.Remote.String()
+		}
+	}
+
+	log.Debugf("Requesting to %s over gRPC for %d conns and %d fds\n", gc.Address, len(conns), len(fds))
+	var resp *agentpb.LookupResponse
+	var err error
+	if gc.stream != nil {
+		resp, err = gc.stream.Lookup(ctx, req)
+	} else {
+		resp, err = agentpb.NewAgentClient(gc.conn).Lookup(withToken(ctx, gc.Token), req)
+	}
+	if err != nil {
+		return &AgentResponse{ip: gc.Address.IP, err: err}
+	}
+
+	var aps []*AgentProcess
+	for _, r := range resp.Results {
+		for _, p := range r.Processes {
+			aps = append(aps, agentProcessFromPB(p))
+		}
+	}
+	return &AgentResponse{ip: gc.Address.IP, hostname: resp.Hostname, version: resp.Version, Processes: aps}
+}
+
+// withToken returns a context that sends given bearer token in the metadata, if it is not empty.
+func withToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// lookupStream sends the lookups of an agent over a single Stream call, one at a time.
+// The stream is opened on first use. It is closed after an error or a timeout and opened again
+// by the next lookup, otherwise a late response would be taken as the response of the next request.
+type lookupStream struct {
+	conn  *grpc.ClientConn
+	token string
+
+	mu     sync.Mutex
+	ctx    context.Context // context of the stream, nil if the stream is closed
+	cancel context.CancelFunc
+	stream agentpb.Agent_StreamClient // nil until the stream is opened
+}
+
+// streamResult is the result of a single request on the stream.
+type streamResult struct {
+	stream agentpb.Agent_StreamClient
+	resp   *agentpb.LookupResponse
+	err    error
+}
+
+// Lookup sends the request on the stream and waits for its response until ctx is done.
+func (ls *lookupStream) Lookup(ctx context.Context, req *agentpb.LookupRequest) (*agentpb.LookupResponse, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.ctx == nil {
+		ls.ctx, ls.cancel = context.WithCancel(withToken(context.Background(), ls.token))
+	}
+
+	// The stream outlives ctx, so it is used in a goroutine that is stopped by closing the stream.
+	done := make(chan streamResult, 1)
+	go func(r streamResult, sctx context.Context) {
+		if r.stream == nil {
+			r.stream, r.err = agentpb.NewAgentClient(ls.conn).Stream(sctx)
+			if r.err != nil {
+				done <- r
+				return
+			}
+		}
+		// io.EOF means that the stream is broken, its error is returned by Recv.
+		if r.err = r.stream.Send(req); r.err == nil || errors.Is(r.err, io.EOF) {
+			r.resp, r.err = r.stream.Recv()
+		}
+		done <- r
+	}(streamResult{stream: ls.stream}, ls.ctx)
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			ls.closeLocked()
+			return nil, r.err
+		}
+		ls.stream = r.stream
+		return r.resp, nil
+	case <-ctx.Done():
+		ls.closeLocked()
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the stream if it is open.
+func (ls *lookupStream) Close() {
+	ls.mu.Lock()
+	ls.closeLocked()
+	ls.mu.Unlock()
+}
+
+// closeLocked closes the stream. mu must be held.
+func (ls *lookupStream) closeLocked() {
+	if ls.cancel != nil {
+		ls.cancel()
+	}
+	ls.ctx, ls.cancel, ls.stream = nil, nil, nil
+}
+
+func agentProcessFromPB(p *agentpb.Process) *AgentProcess {
+	ap := &AgentProcess{
+		ConnectionStatus: p.Status,
+		Pid:              uint32(p.Pid),
+		Port:             p.Port,
+		Fd:               p.Fd,
+		Name:             p.Name,
+		Cmdline:          p.Cmdline,
+		LocalAddress:     p.LocalAddress,
+		RemoteAddress:    p.RemoteAddress,
+		Candidates:       int(p.Candidates),
+		ContainerID:      p.ContainerId,
+		ContainerRuntime: p.ContainerRuntime,
+		ContainerName:    p.ContainerName,
+		PodUID:           p.PodUid,
+		PodName:          p.PodName,
+		PodNamespace:     p.PodNamespace,
+		UID:              p.Uid,
+		Username:         p.Username,
+		PPid:             p.Ppid,
+		ParentCmdline:    p.ParentCmdline,
+		Cwd:              p.Cwd,
+		Exe:              p.Exe,
+		RSS:              p.Rss,
+		NumFDs:           p.NumFds,
+		Tags:             p.Tags,
+		RemoteMismatch:   p.RemoteMismatch,
+	}
+	if p.StartTime != 0 {
+		t := time.UnixMilli(p.StartTime)
+		ap.StartTime = &t
+	}
+	return ap
+}
+
+// capabilityStream is the capability of agents those serve Stream calls.
+const capabilityStream = "stream"
+
+// agentInfo is the info of an agent, returned from GET /v1/info or Info call.
+type agentInfo struct {
+	Hostname     string   `json:"hostname"`
+	Version      string   `json:"version"`
+	Capabilities []string `json:"capabilities"`
+	GRPCPort     uint32   `json:"grpc_port"`
+
+	expires time.Time
+}
+
+type grpcConn struct {
+	*grpc.ClientConn
+	stream   *lookupStream
+	lastUsed time.Time
+}
+
+// agentDialer creates clients for agents according to the configured protocol.
+// It keeps gRPC connections and, in auto protocol, capabilities of agents.
+type agentDialer struct {
+	protocol string
+	port     uint32 // HTTP port
+	grpcPort uint32
+	tls      *tlsutil.Files // nil if agents are connected over plain HTTP
+	token    string
+	client   *http.Client // shared by HTTP clients of all agents
+
+	mu        sync.Mutex
+	grpcConns map[IPPort]*grpcConn
+	infos     map[string]*agentInfo // agent IP -> info
+	noLookup  map[string]time.Time  // agent IP -> expiry, for older agents without lookup endpoint
+}
+
+func newAgentDialer(protocol string, port, grpcPort uint32, tls *tlsutil.Files, token string) *agentDialer {
+	if protocol == "" {
+		protocol = ProtocolHTTP
+	}
+	return &agentDialer{
+		protocol:  protocol,
+		port:      port,
+		grpcPort:  grpcPort,
+		tls:       tls,
+		token:     token,
+		client:    newAgentHTTPClient(tls),
+		grpcConns: make(map[IPPort]*grpcConn),
+		infos:     make(map[string]*agentInfo),
+		noLookup:  make(map[string]time.Time),
+	}
+}
+
+// httpClient returns an HTTP client for the agent with given IP.
+func (d *agentDialer) httpClient(ip string) *AgentClient {
+	ac := NewAgentClient(IPPort{IP: ip, Port: d.port})
+	ac.TLS = d.tls
+	ac.Token = d.token
+	ac.Client = d.client
+	return ac
+}
+
+// grpcClient returns a gRPC client for the agent with given address, reusing the connection to it.
+// Lookups are sent on the stream of the connection if stream is true.
+// Certificates are loaded on every handshake by the callbacks of the TLS config,
+// so rotated files are used by reconnections without recreating the connection.
+func (d *agentDialer) grpcClient(address IPPort, stream bool) (*GRPCAgentClient, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	conn, ok := d.grpcConns[address]
+	if !ok {
+		creds := insecure.NewCredentials()
+		if d.tls != nil {
+			tlsConfig, err := d.tls.ClientConfig(address.IP)
+			if err != nil {
+				return nil, err
+			}
+			creds = credentials.NewTLS(tlsConfig)
+		}
+		cc, err := grpc.NewClient(address.String(), grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		conn = &grpcConn{ClientConn: cc, stream: &lookupStream{conn: cc, token: d.token}}
+		d.grpcConns[address] = conn
+	}
+	conn.lastUsed = time.Now()
+	gc := &GRPCAgentClient{Address: address, Token: d.token, conn: conn.ClientConn}
+	if stream {
+		gc.stream = conn.stream
+	}
+	return gc, nil
+}
+
+// Prune closes gRPC connections of agents those are not asked for a while
+// and removes expired capabilities of agents.
+func (d *agentDialer) Prune() {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for address, conn := range d.grpcConns {
+		if now.Sub(conn.lastUsed) > grpcIdleTimeout {
+			log.Debugf("Closing idle gRPC connection to %s\n", address)
+			conn.stream.Close()
+			conn.Close()
+			delete(d.grpcConns, address)
+		}
+	}
+	for ip, info := range d.infos {
+		if now.After(info.expires) {
+			delete(d.infos, ip)
+		}
+	}
+	for ip, expires := range d.noLookup {
+		if now.After(expires) {
+			delete(d.noLookup, ip)
+		}
+	}
+}
+
+// Get gets process info from the agent with given IP over the configured protocol.
+func (d *agentDialer) Get(ctx context.Context, ip string, conns []AgentConn, fds []int32) *AgentResponse {
+	switch d.protocol {
+	case ProtocolGRPC:
+		address := IPPort{IP: ip, Port: d.grpcPort}
+		ar := d.grpcGet(ctx, address, d.grpcInfo(ctx, address), conns, fds)
+		if ar.err != nil {
+			// agent may be upgraded or downgraded, negotiate again on next poll.
+			d.forget(ip)
+		}
+		return ar
+	case ProtocolAuto:
+		info := d.info(ctx, ip)
+		if info == nil || !slices.Contains(info.Capabilities, ProtocolGRPC) {
+			return d.httpGet(ctx, ip, conns, fds)
+		}
+		ar := d.grpcGet(ctx, IPPort{IP: ip, Port: info.GRPCPort}, info, conns, fds)
+		if ar.err == nil {
+			return ar
+		}
+		// agent may be downgraded or gRPC may be blocked, ask capabilities again on next poll.
+		log.Debugf("gRPC request to %s failed, falling back to HTTP: %s\n", ip, ar.err)
+		d.forget(ip)
+		return d.httpGet(ctx, ip, conns, fds)
+	default:
+		return d.httpGet(ctx, ip, conns, fds)
+	}
+}
+
+// grpcGet gets process info from the agent with given address over gRPC,
+// on a stream if the info of the agent has stream capability.
+func (d *agentDialer) grpcGet(ctx context.Context, address IPPort, info *agentInfo, conns []AgentConn, fds []int32) *AgentResponse {
+	stream := info != nil && slices.Contains(info.Capabilities, capabilityStream)
+	gc, err := d.grpcClient(address, stream)
+	if err != nil {
+		return &AgentResponse{ip: address.IP, err: err}
+	}
+	return gc.Get(ctx, conns, fds)
+}
+
+// httpGet gets process info from the agent with given IP over HTTP. Agents without lookup endpoint
+// are remembered for infoTTL and asked with /proc directly, instead of probing the endpoint every time.
+func (d *agentDialer) httpGet(ctx context.Context, ip string, conns []AgentConn, fds []int32) *AgentResponse {
+	ac := d.httpClient(ip)
+	d.mu.Lock()
+	expires, ok := d.noLookup[ip]
+	d.mu.Unlock()
+	if ok && time.Now().Before(expires) {
+		return ac.GetProc(ctx, conns, fds)
+	}
+
+	ar := ac.Lookup(ctx, conns, fds)
+	if !errors.Is(ar.err, errLookupNotSupported) {
+		return ar
+	}
+	log.Debugf("%s, falling back to /proc\n", ar.err)
+	d.mu.Lock()
+	d.noLookup[ip] = time.Now().Add(infoTTL)
+	d.mu.Unlock()
+	return ac.GetProc(ctx, conns, fds)
+}
+
+// info returns the cached info of the agent with given IP, asking the agent over HTTP if it is not known.
+// Returns nil if the agent could not be asked.
+func (d *agentDialer) info(ctx context.Context, ip string) *agentInfo {
+	if info := d.cachedInfo(ip); info != nil {
+		return info
+	}
+
+	info, err := d.httpClient(ip).Info(ctx)
+	if err != nil {
+		log.Debugf("Can not get info of agent %s: %s\n", ip, err)
+		return nil
+	}
+	if info.GRPCPort == 0 {
+		info.GRPCPort = d.grpcPort
+	}
+	d.setInfo(ip, info)
+	return info
+}
+
+// grpcInfo returns the cached info of the agent with given gRPC address, asking the agent over gRPC
+// if it is not known. Returns nil if the agent could not be asked.
+func (d *agentDialer) grpcInfo(ctx context.Context, address IPPort) *agentInfo {
+	if info := d.cachedInfo(address.IP); info != nil {
+		return info
+	}
+
+	gc, err := d.grpcClient(address, false)
+	if err != nil {
+		return nil
+	}
+	info, err := gc.Info(ctx)
+	if status.Code(err) == codes.Unimplemented { // agent serves Lookup only.
+		info, err = &agentInfo{}, nil
+	}
+	if err != nil {
+		log.Debugf("Can not get info of agent %s: %s\n", address, err)
+		return nil
+	}
+	d.setInfo(address.IP, info)
+	return info
+}
+
+// cachedInfo returns the info of the agent with given IP, nil if it is not known or expired.
+func (d *agentDialer) cachedInfo(ip string) *agentInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	info, ok := d.infos[ip]
+	if !ok || time.Now().After(info.expires) {
+		return nil
+	}
+	return info
+}
+
+// setInfo caches the info of the agent with given IP for infoTTL.
+func (d *agentDialer) setInfo(ip string, info *agentInfo) {
+	info.expires = time.Now().Add(infoTTL)
+	d.mu.Lock()
+	d.infos[ip] = info
+	d.mu.Unlock()
+}
+
+// forget removes the cached info of the agent with given IP.
+func (d *agentDialer) forget(ip string) {
+	d.mu.Lock()
+	delete(d.infos, ip)
+	d.mu.Unlock()
+}
+
+// Info gets the hostname and capabilities of the agent. Older agents are returned with no capabilities.
+func (ac *AgentClient) Info(ctx context.Context) (*agentInfo, error) {
+	url := fmt.Sprintf("%s/v1/info", ac.baseURL())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	auth.SetHeader(req, ac.Token)
+	client, err := ac.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusMethodNotAllowed {
+		return &agentInfo{}, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{response.StatusCode, response.Status}
+	}
+
+	var info agentInfo
+	err = json.NewDecoder(response.Body).Decode(&info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// agentStatusTTL is the duration that statuses of agents those are not requested anymore are kept.
+const agentStatusTTL = time.Hour
+
+// Agent modes
+const (
+	AgentModePull = "pull"
+	AgentModePush = "push"
+)
+
+// AgentStatus is the status of an agent across polls.
+type AgentStatus struct {
+	IP                  string     `json:"ip"`
+	Hostname            string     `json:"hostname,omitempty"`
+	Version             string     `json:"version,omitempty"`
+	Mode                string     `json:"mode"` // "pull" or "push"
+	Up                  bool       `json:"up"`   // last poll of the agent is successful
+	Skipped             bool       `json:"skipped,omitempty"`
+	LatencySeconds      float64    `json:"latency_seconds"` // duration of the last request
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorTime       *time.Time `json:"last_error_time,omitempty"`
+
+	lastSeen time.Time
+}
+
+// agentObserver observes every request to agents, including retries.
+type agentObserver interface {
+	ObserveAgentRequest(ip string, d time.Duration, err error)
+}
+
+// AgentStatuses tracks the statuses of agents.
+type AgentStatuses struct {
+	mu       sync.Mutex
+	agents   map[string]*AgentStatus // agent IP -> status
+	observer agentObserver           // nil if requests are not observed
+}
+
+// NewAgentStatuses creates and returns a new AgentStatuses.
+func NewAgentStatuses() *AgentStatuses {
+	return &AgentStatuses{agents: make(map[string]*AgentStatus)}
+}
+
+// get returns the status of the agent with given IP, creating it if it does not exist. mu must be held.
+func (as *AgentStatuses) get(ip string) *AgentStatus {
+	st, ok := as.agents[ip]
+	if !ok {
+		st = &AgentStatus{IP: ip}
+		as.agents[ip] = st
+	}
+	st.lastSeen = time.Now()
+	return st
+}
+
+// Observe observes a single request to the agent with given IP.
+func (as *AgentStatuses) Observe(ip string, d time.Duration, err error) {
+	if as.observer != nil {
+		as.observer.ObserveAgentRequest(ip, d, err)
+	}
+}
+
+// Record records the final response of the agent in a poll, latency is the duration of its last request.
+func (as *AgentStatuses) Record(ar *AgentResponse, latency time.Duration) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	st := as.get(ar.ip)
+	st.Mode = AgentModePull
+	st.Skipped = false
+	st.LatencySeconds = latency.Seconds()
+	as.update(st, ar)
+}
+
+// RecordPush records the use of a snapshot pushed by the agent.
+func (as *AgentStatuses) RecordPush(ar *AgentResponse) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	st := as.get(ar.ip)
+	st.Mode = AgentModePush
+	st.Skipped = false
+	st.LatencySeconds = 0
+	as.update(st, ar)
+}
+
+// RecordSkipped records that the agent is skipped by the circuit breaker.
+func (as *AgentStatuses) RecordSkipped(ip string, err error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	st := as.get(ip)
+	st.Mode = AgentModePull
+	st.Skipped = true
+	st.Up = false
+	st.LastError = err.Error()
+}
+
+// update sets the result of given response. mu must be held.
+func (as *AgentStatuses) update(st *AgentStatus, ar *AgentResponse) {
+	now := time.Now()
+	if ar.hostname != "" {
+		st.Hostname = ar.hostname
+	}
+	if ar.err != nil {
+		st.Up = false
+		st.ConsecutiveFailures++
+		st.LastError = ar.err.Error()
+		st.LastErrorTime = &now
+		return
+	}
+	st.Up = true
+	st.ConsecutiveFailures = 0
+	st.LastSuccess = &now
+	st.Version = ar.version
+}
+
+// List returns the statuses of agents sorted by IP. Agents those are not requested for a while are removed.
+func (as *AgentStatuses) List() []AgentStatus {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	statuses := make([]AgentStatus, 0, len(as.agents))
+	for ip, st := range as.agents {
+		if time.Since(st.lastSeen) > agentStatusTTL {
+			delete(as.agents, ip)
+			continue
+		}
+		statuses = append(statuses, *st)
+	}
+	slices.SortFunc(statuses, func(a, b AgentStatus) int { return strings.Compare(a.IP, b.IP) })
+	return statuses
+}
+
+// agentErrorReason classifies the error of an agent request for metrics.
+func agentErrorReason(err error) string {
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), status.Code(err) == codes.DeadlineExceeded:
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr), status.Code(err) == codes.Unavailable:
+		return "connection"
+	default:
+		return "response"
+	}
+}
+
+// AgentsResponse is the response of agents endpoint.
+type AgentsResponse struct {
+	Agents []AgentStatus `json:"agents"`
+}
+
+// Agents is a handler for returning statuses of agents.
+func (s *Server) Agents(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(&AgentsResponse{Agents: s.Fetcher.Statuses.List()})
+	if err != nil {
+		http.Error(w, "Can not encode agent statuses", http.StatusInternalServerError)
+	}
+}
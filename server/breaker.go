@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+)
+
+// circuitBreaker skips agents those failed repeatedly for a cool-off period,
+// so that dead agents do not hold workers until their timeouts on every poll.
+type circuitBreaker struct {
+	failures int           // consecutive failures to open the circuit, disabled if zero
+	cooldown time.Duration // duration that the agent is skipped
+
+	mu     sync.Mutex
+	agents map[string]*breakerState // agent IP -> state
+}
+
+type breakerState struct {
+	failures  int
+	lastErr   error
+	openUntil time.Time
+}
+
+// circuitOpenError is returned for the agents those are skipped.
+type circuitOpenError struct {
+	failures  int
+	lastErr   error
+	openUntil time.Time
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("agent is skipped for %s after %d consecutive failures, last error: %s",
+		time.Until(e.openUntil).Round(time.Second), e.failures, e.lastErr)
+}
+
+func newCircuitBreaker(failures int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failures: failures,
+		cooldown: cooldown,
+		agents:   make(map[string]*breakerState),
+	}
+}
+
+// Allow returns an error if the agent with given IP should be skipped.
+// After cooldown, the agent is requested once again and skipped again if it still fails.
+func (cb *circuitBreaker) Allow(ip string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	st, ok := cb.agents[ip]
+	if !ok || cb.failures <= 0 || st.failures < cb.failures || time.Now().After(st.openUntil) {
+		return nil
+	}
+	return &circuitOpenError{failures: st.failures, lastErr: st.lastErr, openUntil: st.openUntil}
+}
+
+// Success resets the failures of the agent with given IP.
+func (cb *circuitBreaker) Success(ip string) {
+	cb.mu.Lock()
+	delete(cb.agents, ip)
+	cb.mu.Unlock()
+}
+
+// Failure records a failure of the agent with given IP and opens the circuit if the limit is reached.
+func (cb *circuitBreaker) Failure(ip string, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	st, ok := cb.agents[ip]
+	if !ok {
+		st = new(breakerState)
+		cb.agents[ip] = st
+	}
+	st.failures++
+	st.lastErr = err
+	if cb.failures > 0 && st.failures >= cb.failures {
+		st.openUntil = time.Now().Add(cb.cooldown)
+		log.Infof("Agent %s failed %d times in a row, skipping it for %s: %s\n", ip, st.failures, cb.cooldown, err)
+	}
+}
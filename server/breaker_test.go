@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	tests := []struct {
+		name     string
+		limit    int
+		cooldown time.Duration
+		failures int
+		success  bool // a success is recorded after the failures
+		wantOpen bool
+	}{
+		{"no failures", 3, time.Minute, 0, false, false},
+		{"below limit", 3, time.Minute, 2, false, false},
+		{"at limit", 3, time.Minute, 3, false, true},
+		{"above limit", 3, time.Minute, 5, false, true},
+		{"reset by success", 3, time.Minute, 3, true, false},
+		{"cooldown passed", 3, -time.Second, 3, false, false},
+		{"disabled", 0, time.Minute, 10, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := newCircuitBreaker(tt.limit, tt.cooldown)
+			for range tt.failures {
+				cb.Failure("10.0.0.5", errTimeout)
+			}
+			if tt.success {
+				cb.Success("10.0.0.5")
+			}
+			err := cb.Allow("10.0.0.5")
+			if open := err != nil; open != tt.wantOpen {
+				t.Fatalf("Allow() = %v, want open %t", err, tt.wantOpen)
+			}
+			var coe *circuitOpenError
+			if err != nil && (!errors.As(err, &coe) || coe.failures != tt.failures || coe.lastErr != errTimeout) {
+				t.Errorf("Allow() = %#v, want circuitOpenError with %d failures", err, tt.failures)
+			}
+			if err := cb.Allow("10.0.0.6"); err != nil {
+				t.Errorf("Allow() of another agent = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -2,91 +2,151 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"kimo/auth"
 	"kimo/config"
+	"kimo/tlsutil"
+	"math/rand/v2"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Fetcher fetches process info(s) from resources
 type Fetcher struct {
-	MysqlClient    *MysqlClient
-	TCPProxyClient *TCPProxyClient
+	Sources []Source
+	Hops    []Hop // resolved in order, starting from the one closest to databases.
 
 	AgentListenPort uint32
+	AgentTLS        *tlsutil.Files // nil if agents are connected over plain HTTP
+	AgentToken      string         // bearer token sent to agents, empty if not configured
+	Registry        *AgentRegistry // snapshots of agents in push mode
+	Statuses        *AgentStatuses // statuses of agents across polls
+
+	AgentConcurrency  int           // maximum number of agents those are requested at the same time
+	AgentTimeout      time.Duration // timeout of a single request to an agent
+	AgentRetries      int           // number of retries after a failed request
+	AgentRetryBackoff time.Duration // wait before the first retry, doubled on every retry
+
+	agents  *agentDialer
+	breaker *circuitBreaker
+	cache   *agentCache // nil if agent results are not cached
 }
 
-// RawProcess combines resources information(mysql row, tcp proxy conn, agent process etc.)
+// RawProcess combines resources information(database row, resolved client address, agent process etc.)
 type RawProcess struct {
-	MysqlRow     *MysqlRow
-	TCPProxyConn *TCPProxyConn
-	Process      *EnhancedAgentProcess
-
-	TCPProxyEnabled bool
+	Target   string // name of the source that the row is fetched from.
+	Row      Row
+	Address  IPPort // client address after resolving hops.
+	Server   IPPort // address that the client is connected to (database or the last resolved hop), zero value if unknown.
+	SocketFD int32  // fd of the unix socket connection in the database server process, 0 for TCP connections.
+	Process  *EnhancedAgentProcess
+
+	unresolvedHop string // name of the hop that the connection could not be found on.
+	cached        bool   // process is found in the agent cache.
 }
 
-// AgentAddress returns agent address considering proxy usage.
+// AgentAddress returns agent address considering hop usage.
 func (rp *RawProcess) AgentAddress() IPPort {
-	if rp.TCPProxyConn != nil {
-		return IPPort{IP: rp.TCPProxyConn.ClientOut.IP, Port: rp.TCPProxyConn.ClientOut.Port}
-	}
-	return IPPort{IP: rp.MysqlRow.Address.IP, Port: rp.MysqlRow.Address.Port}
+	return rp.Address
+}
+
+// IsUnixSocket returns true if the process is connected over a unix socket.
+func (rp *RawProcess) IsUnixSocket() bool {
+	return rp.Address.Port == 0
 }
 
 // Detail returns error detail for the process.
 func (rp *RawProcess) Detail() string {
-	if rp.TCPProxyEnabled && rp.TCPProxyConn == nil {
-		return "No connection found on tcpproxy"
+	if rp.IsUnixSocket() && rp.SocketFD == 0 {
+		return "Unix socket connection fd is unknown, performance_schema is required"
+	}
+
+	if rp.unresolvedHop != "" {
+		return fmt.Sprintf("No connection found on %s", rp.unresolvedHop)
 	}
 
 	if rp.Process != nil {
 		if rp.Process.err != nil {
 			return rp.Process.err.Error()
 		}
+		if rp.Process.RemoteMismatch {
+			return fmt.Sprintf("Matched by port only, the connection is to %s instead of %s", rp.Process.RemoteAddress, rp.Server)
+		}
 	}
 	return ""
 }
 
-// NewFetcher creates and returns a new Fetcher.
+// NewFetcher creates and returns a new Fetcher with sources and hops of registered types.
 func NewFetcher(cfg config.ServerConfig) *Fetcher {
 	f := new(Fetcher)
-	f.MysqlClient = NewMysqlClient(cfg.MySQL)
-	if cfg.TCPProxy.MgmtAddress != "" {
-		f.TCPProxyClient = NewTCPProxyClient(cfg.TCPProxy)
-	}
+	f.Sources = newSources(cfg)
+	f.Hops = newHops(cfg)
 	f.AgentListenPort = cfg.Agent.Port
+	f.Registry = NewAgentRegistry(cfg.Agent.Push.TTL)
+	f.Statuses = NewAgentStatuses()
+	token, err := auth.LoadToken(cfg.Agent.TokenFile, cfg.Agent.TokenEnv)
+	if err != nil {
+		log.Errorf("Can not load agent token: %s\n", err)
+	}
+	f.AgentToken = token
+	if cfg.Agent.TLS.Enabled() {
+		f.AgentTLS = tlsutil.NewFiles(cfg.Agent.TLS)
+		if err := f.AgentTLS.Load(); err != nil {
+			log.Errorf("Can not load agent TLS files: %s\n", err)
+		}
+	}
+	f.agents = newAgentDialer(cfg.Agent.Protocol, f.AgentListenPort, cfg.Agent.GRPCPort, f.AgentTLS, f.AgentToken)
+	f.AgentConcurrency = cfg.Agent.MaxConcurrency
+	f.AgentTimeout = cfg.Agent.Timeout
+	f.AgentRetries = cfg.Agent.Retries
+	f.AgentRetryBackoff = cfg.Agent.RetryBackoff
+	f.breaker = newCircuitBreaker(cfg.Agent.BreakerFailures, cfg.Agent.BreakerCooldown)
+	if cfg.Agent.CacheTTL > 0 {
+		f.cache = newAgentCache(cfg.Agent.CacheTTL)
+	}
 	return f
 }
 
+// Source returns the source with given target name.
+func (f *Fetcher) Source(target string) Source {
+	for _, src := range f.Sources {
+		if src.Name() == target {
+			return src
+		}
+	}
+	return nil
+}
+
 // createRawProcesses creates raw process and inserts given param.
-func createRawProcesses(rows []*MysqlRow) []*RawProcess {
-	log.Debugln("Creating raw processes...")
+func createRawProcesses(target string, rows []Row) []*RawProcess {
+	log.Debugf("Creating raw processes for %s...\n", target)
 	var rps []*RawProcess
 	for _, row := range rows {
-		rp := &RawProcess{MysqlRow: row}
+		rp := &RawProcess{Target: target, Row: row, Address: row.ClientAddress()}
+		if sr, ok := row.(SocketRow); ok {
+			rp.SocketFD = sr.SocketFD()
+		}
+		if sr, ok := row.(ServerRow); ok {
+			rp.Server = sr.ServerAddress()
+		}
 		rps = append(rps, rp)
 	}
 	return rps
 }
 
-// addProxyConns adds TCPProxy connection info if TCPProxy is enabled.
-func addProxyConns(rps []*RawProcess, conns []*TCPProxyConn) {
-	log.Debugln("Adding tcpproxy conns...")
-	for _, rp := range rps {
-		conn := findTCPProxyConn(rp.AgentAddress(), conns)
-		if conn != nil {
-			rp.TCPProxyConn = conn
-		}
-	}
-}
-
-// addAgentProcesses adds Proxy info to raw processes.
+// addAgentProcesses adds Proxy info to raw processes. Processes those are found in the cache are kept.
 func addAgentProcesses(rps []*RawProcess, ars []*AgentResponse) {
 	log.Debugln("Adding agent processes...")
 	for _, rp := range rps {
-		eap := findProcess(rp.AgentAddress(), ars)
+		if rp.cached {
+			continue
+		}
+		eap := findProcess(rp.AgentAddress(), rp.Server, rp.SocketFD, ars)
 		if eap != nil {
 			rp.Process = eap
 		}
@@ -97,147 +157,296 @@ func addAgentProcesses(rps []*RawProcess, ars []*AgentResponse) {
 func (f *Fetcher) FetchAll(ctx context.Context) ([]*RawProcess, error) {
 	log.Debugln("Fetching resources...")
 
-	log.Debugln("Fetching mysql rows...")
-	rows, err := f.fetchMysql(ctx)
+	log.Debugln("Fetching sources...")
+	rps, err := f.fetchSources(ctx)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("Got %d mysql rows \n", len(rows))
-
-	rps := createRawProcesses(rows)
-
-	if f.TCPProxyClient != nil {
-		for _, rp := range rps {
-			rp.TCPProxyEnabled = true
-		}
 
-		log.Debugln("Fetching tcpproxy conns...")
-		tps, err := f.fetchTcpProxy(ctx)
+	for _, hop := range f.Hops {
+		log.Debugf("Resolving addresses on %s...\n", hop.Name())
+		err = f.resolveHop(ctx, hop, rps)
 		if err != nil {
 			return nil, err
 		}
-		log.Debugf("Got %d tcpproxy conns \n", len(tps))
-		addProxyConns(rps, tps)
 	}
 
+	n := f.addCachedProcesses(rps)
+	log.Debugf("%d processes are found in cache \n", n)
+
 	log.Debugln("Fetching agents...")
 	ars := f.fetchAgents(ctx, rps)
 	log.Debugf("Got %d agent responses \n", len(ars))
 
 	addAgentProcesses(rps, ars)
+	f.cacheAgentProcesses(rps)
 
 	log.Debugf("%d raw processes are generated \n", len(rps))
 	return rps, nil
 }
 
-// fetchMysql retrieves MySQL data with timeout.
+// fetchSources concurrently retrieves rows from all sources and creates raw processes.
+// It fails only if none of the sources could be fetched; errors of other sources are logged.
+func (f *Fetcher) fetchSources(ctx context.Context) ([]*RawProcess, error) {
+	if len(f.Sources) == 0 {
+		return nil, errors.New("no database target is configured")
+	}
+
+	type result struct {
+		rows []Row
+		err  error
+	}
+
+	results := make([]result, len(f.Sources))
+	var wg sync.WaitGroup
+	for i, src := range f.Sources {
+		wg.Add(1)
+		go func(i int, src Source) {
+			defer wg.Done()
+			rows, err := f.fetchSource(ctx, src)
+			results[i] = result{rows, err}
+		}(i, src)
+	}
+	wg.Wait()
+
+	var rps []*RawProcess
+	var errs []error
+	for i, r := range results {
+		target := f.Sources[i].Name()
+		if r.err != nil {
+			log.Errorf("Can not fetch target %s: %s\n", target, r.err)
+			errs = append(errs, fmt.Errorf("%s: %w", target, r.err))
+			continue
+		}
+		log.Debugf("Got %d rows from %s \n", len(r.rows), target)
+		rps = append(rps, createRawProcesses(target, r.rows)...)
+	}
+
+	if len(errs) == len(f.Sources) {
+		return nil, errors.Join(errs...)
+	}
+	return rps, nil
+}
+
+// fetchSource retrieves rows of a source with timeout.
 // It performs the fetch operation in a separate goroutine to prevent blocking.
-func (f *Fetcher) fetchMysql(ctx context.Context) ([]*MysqlRow, error) {
+func (f *Fetcher) fetchSource(ctx context.Context, src Source) ([]Row, error) {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
 
 	type result struct {
-		rows []*MysqlRow
+		rows []Row
 		err  error
 	}
 
 	resultChan := make(chan result, 1)
 	go func() {
-		rows, err := f.MysqlClient.Get(ctx)
+		rows, err := src.Fetch(ctx)
 		resultChan <- result{rows, err}
 	}()
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("fetch mysql operation timed out: %w", ctx.Err())
+		return nil, fmt.Errorf("fetch %s operation timed out: %w", src.Name(), ctx.Err())
 	case r := <-resultChan:
 		return r.rows, r.err
 	}
 }
 
-// fetchTcpProxy retrieves TCP proxy connections with timeout.
-// It performs the fetch operation in a separate goroutine to prevent blocking.
-func (f *Fetcher) fetchTcpProxy(ctx context.Context) ([]*TCPProxyConn, error) {
+// resolveHop replaces addresses of raw processes with the client side addresses on given hop.
+// Raw processes those could not be resolved on a previous hop and unix socket connections are skipped.
+// It performs the resolve operation in a separate goroutine to prevent blocking.
+func (f *Fetcher) resolveHop(ctx context.Context, hop Hop, rps []*RawProcess) error {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
 
+	var addrs []IPPort
+	for _, rp := range rps {
+		if rp.unresolvedHop == "" && !rp.IsUnixSocket() {
+			addrs = append(addrs, rp.Address)
+		}
+	}
+
 	type result struct {
-		conns []*TCPProxyConn
-		err   error
+		resolved map[IPPort]HopConn
+		err      error
 	}
 
 	resultChan := make(chan result, 1)
 	go func() {
-		conns, err := f.TCPProxyClient.Get(ctx)
-		resultChan <- result{conns, err}
+		resolved, err := hop.Resolve(ctx, addrs)
+		resultChan <- result{resolved, err}
 	}()
 
 	select {
 	case <-ctx.Done():
-		return nil, fmt.Errorf("fetch tcpproxy operation timed out: %w", ctx.Err())
+		return fmt.Errorf("resolve %s operation timed out: %w", hop.Name(), ctx.Err())
 	case r := <-resultChan:
-		return r.conns, r.err
+		if r.err != nil {
+			return r.err
+		}
+		for _, rp := range rps {
+			if rp.unresolvedHop != "" || rp.IsUnixSocket() {
+				continue
+			}
+			if conn, ok := r.resolved[rp.Address]; ok {
+				rp.Address = conn.Client
+				rp.Server = conn.Server
+			} else {
+				rp.unresolvedHop = hop.Name()
+			}
+		}
+		return nil
 	}
 }
 
-// fetchAgents concurrently retrieves process information from multiple agents with timeout.
+// agentQuery holds the connections and unix socket fds to be asked to an agent.
+type agentQuery struct {
+	conns []AgentConn
+	fds   []int32
+}
+
+// fetchAgents concurrently retrieves process information from multiple agents with a bounded number of workers.
 func (f *Fetcher) fetchAgents(ctx context.Context, rps []*RawProcess) []*AgentResponse {
 	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
 	defer cancel()
 
-	agentIPPorts := make(map[string][]uint32)
+	f.agents.Prune()
+
+	agentQueries := make(map[string]*agentQuery)
 	for _, rp := range rps {
+		if rp.cached || rp.IsUnixSocket() && rp.SocketFD == 0 {
+			continue
+		}
 		addr := rp.AgentAddress()
-		agentIPPorts[addr.IP] = append(agentIPPorts[addr.IP], addr.Port)
+		q, ok := agentQueries[addr.IP]
+		if !ok {
+			q = new(agentQuery)
+			agentQueries[addr.IP] = q
+		}
+		if rp.IsUnixSocket() {
+			q.fds = append(q.fds, rp.SocketFD)
+		} else {
+			q.conns = append(q.conns, AgentConn{Local: addr, Remote: rp.Server})
+		}
 	}
 
-	done := make(chan struct{}, 1)
-	resultChan := make(chan *AgentResponse, len(agentIPPorts))
+	var ars []*AgentResponse
 
-	// Get responses from agents
-	go func() {
-		// todo: limit concurrent goroutines.
-		var wg sync.WaitGroup
-		for agentIP, ports := range agentIPPorts {
-			wg.Add(1)
-
-			agentAddr := IPPort{IP: agentIP, Port: f.AgentListenPort}
-			go func(address IPPort, ports []uint32) {
-				defer wg.Done()
-
-				ac := NewAgentClient(address)
-				ar := ac.Get(ctx, ports)
-				resultChan <- ar
-			}(agentAddr, ports)
-		}
-		wg.Wait()
-		close(resultChan) // Close channel to signal no more results
-		done <- struct{}{}
-	}()
+	// Use snapshots of agents those push, unix socket fds can only be asked to the agent.
+	for agentIP, q := range agentQueries {
+		if len(q.fds) > 0 {
+			continue
+		}
+		if snap := f.Registry.Get(agentIP); snap != nil {
+			log.Debugf("Using pushed snapshot of %s\n", agentIP)
+			ar := &AgentResponse{ip: agentIP, hostname: snap.Hostname, version: snap.Version, Processes: snap.Processes}
+			f.Statuses.RecordPush(ar)
+			ars = append(ars, ar)
+			delete(agentQueries, agentIP)
+		}
+	}
 
-	// Collect results
-	var ars []*AgentResponse
+	// Skip agents those failed repeatedly, their connections show the reason.
+	for agentIP := range agentQueries {
+		if err := f.breaker.Allow(agentIP); err != nil {
+			log.Debugf("Skipping agent %s: %s\n", agentIP, err)
+			f.Statuses.RecordSkipped(agentIP, err)
+			ars = append(ars, &AgentResponse{ip: agentIP, err: err})
+			delete(agentQueries, agentIP)
+		}
+	}
 
-	// Use a separate goroutine to collect results
-	arsDone := make(chan struct{})
-	go func() {
-		for result := range resultChan {
-			if result != nil {
-				ars = append(ars, result)
+	// Get responses from agents with a bounded number of workers.
+	workers := min(max(f.AgentConcurrency, 1), len(agentQueries))
+	agentIPs := make(chan string)
+	resultChan := make(chan *AgentResponse, len(agentQueries))
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for agentIP := range agentIPs {
+				q := agentQueries[agentIP]
+				resultChan <- f.fetchAgent(ctx, agentIP, q)
 			}
+		}()
+	}
+	for agentIP := range agentQueries {
+		agentIPs <- agentIP
+	}
+	close(agentIPs)
+	wg.Wait()
+	close(resultChan)
+
+	for ar := range resultChan {
+		ars = append(ars, ar)
+	}
+	log.Debugf("All agents are visited. %d responses are received \n", len(ars))
+	return ars
+}
+
+// fetchAgent gets process info from the agent with given IP, retrying failed requests with backoff.
+// The result is recorded to the circuit breaker and the status of the agent.
+func (f *Fetcher) fetchAgent(ctx context.Context, agentIP string, q *agentQuery) *AgentResponse {
+	var ar *AgentResponse
+	var latency time.Duration
+	backoff := f.AgentRetryBackoff
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		ar = f.getAgent(ctx, agentIP, q)
+		latency = time.Since(start)
+		f.Statuses.Observe(agentIP, latency, ar.err)
+		if ar.err == nil || !retryable(ar.err) || attempt >= f.AgentRetries || ctx.Err() != nil {
+			break
 		}
-		close(arsDone) // close channel to signal result collection is done.
-	}()
+		wait := jitter(backoff)
+		log.Debugf("Request to agent %s failed, retrying in %s: %s\n", agentIP, wait, ar.err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
 
-	// Wait for either completion or timeout
-	select {
-	case <-ctx.Done():
-		log.Infof("fetch agents operation stopped: %s", ctx.Err())
-		return ars
-	case <-done:
-		// Wait for all results to be collected
-		<-arsDone
-		log.Debugf("All agents are visited. %d processes found \n", len(ars))
-		return ars
+	switch {
+	case ar.err == nil:
+		f.breaker.Success(agentIP)
+	case retryable(ar.err):
+		f.breaker.Failure(agentIP, ar.err)
+	}
+	f.Statuses.Record(ar, latency)
+	return ar
+}
+
+// getAgent gets process info from the agent with given IP with the agent timeout.
+func (f *Fetcher) getAgent(ctx context.Context, agentIP string, q *agentQuery) *AgentResponse {
+	if f.AgentTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.AgentTimeout)
+		defer cancel()
+	}
+	return f.agents.Get(ctx, agentIP, q.conns, q.fds)
+}
+
+// retryable returns true if the request may succeed when it is retried: transport errors, timeouts and
+// server errors. Other errors (e.g. unauthorized or bad request) are not retried and do not trip the circuit breaker.
+func retryable(err error) bool {
+	var hse *httpStatusError
+	if errors.As(err, &hse) {
+		return hse.code >= 500
+	}
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.Unauthenticated, codes.Unimplemented:
+		return false
+	}
+	return true
+}
+
+// jitter returns a random duration between the half and 1.5 times of d, so that retries of agents are spread.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
 	}
+	return d/2 + rand.N(d)
 }
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeRow is a database row with a fixed client address.
+type fakeRow struct {
+	id   int32
+	addr IPPort
+}
+
+func (r fakeRow) ClientAddress() IPPort { return r.addr }
+func (r fakeRow) ConnectionID() int32   { return r.id }
+func (r fakeRow) Fill(kp *KimoProcess)  { kp.ID = r.id }
+
+// fakeSource returns fixed rows or an error.
+type fakeSource struct {
+	name string
+	rows []Row
+	err  error
+}
+
+func (s *fakeSource) Name() string                             { return s.name }
+func (s *fakeSource) Fetch(ctx context.Context) ([]Row, error) { return s.rows, s.err }
+
+// fakeHop resolves addresses from a fixed map.
+type fakeHop struct {
+	conns map[IPPort]HopConn
+}
+
+func (h *fakeHop) Name() string { return "proxy" }
+func (h *fakeHop) Resolve(ctx context.Context, addrs []IPPort) (map[IPPort]HopConn, error) {
+	resolved := make(map[IPPort]HopConn)
+	for _, addr := range addrs {
+		if conn, ok := h.conns[addr]; ok {
+			resolved[addr] = conn
+		}
+	}
+	return resolved, nil
+}
+
+// newTestFetcher returns a fetcher for given sources and hops, agents are only known by their pushed snapshots.
+func newTestFetcher(sources []Source, hops []Hop) *Fetcher {
+	return &Fetcher{
+		Sources:  sources,
+		Hops:     hops,
+		Registry: NewAgentRegistry(time.Minute),
+		Statuses: NewAgentStatuses(),
+		agents:   newAgentDialer(ProtocolHTTP, 3333, 0, nil, ""),
+		breaker:  newCircuitBreaker(0, 0),
+		cache:    newAgentCache(time.Minute),
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	proxy := IPPort{IP: "10.0.0.9", Port: 6033}
+	src := &fakeSource{name: "db1", rows: []Row{
+		fakeRow{id: 1, addr: IPPort{IP: "10.0.0.9", Port: 40001}}, // through the proxy, client is on a pushing agent
+		fakeRow{id: 2, addr: IPPort{IP: "10.0.0.9", Port: 40002}}, // through the proxy, no such connection on it
+	}}
+	hop := &fakeHop{conns: map[IPPort]HopConn{
+		{IP: "10.0.0.9", Port: 40001}: {Client: IPPort{IP: "10.0.0.5", Port: 51234}, Server: proxy},
+	}}
+	f := newTestFetcher([]Source{src, &fakeSource{name: "db2", err: errors.New("connection refused")}}, []Hop{hop})
+	f.Registry.Register(&AgentSnapshot{
+		Hostname: "app-1",
+		IPs:      []string{"10.0.0.5"},
+		Processes: []*AgentProcess{
+			{Pid: 1000, Port: 51234, Name: "app", LocalAddress: "10.0.0.5:51234", RemoteAddress: proxy.String()},
+			{Pid: 1001, Port: 51235, Name: "other", LocalAddress: "10.0.0.5:51235", RemoteAddress: proxy.String()},
+		},
+	}, "")
+	// connections those are not found on the hop are asked to the host of the hop.
+	f.Registry.Register(&AgentSnapshot{Hostname: "proxy-1", IPs: []string{"10.0.0.9"}}, "")
+
+	for _, poll := range []string{"first", "cached"} {
+		rps, err := f.FetchAll(context.Background())
+		if err != nil {
+			t.Fatalf("%s poll: FetchAll() error = %v", poll, err)
+		}
+		if len(rps) != 2 {
+			t.Fatalf("%s poll: FetchAll() returned %d processes, want 2", poll, len(rps))
+		}
+
+		found, unresolved := rps[0], rps[1]
+		if found.Target != "db1" || found.Address != (IPPort{IP: "10.0.0.5", Port: 51234}) || found.Server != proxy {
+			t.Errorf("%s poll: process is at %s of %s via %s, want 10.0.0.5:51234 of db1 via %s",
+				poll, found.Address, found.Target, found.Server, proxy)
+		}
+		if found.Process == nil || found.Process.Pid != 1000 || found.Process.Host() != "app-1" {
+			t.Errorf("%s poll: agent process = %+v, want pid 1000 on app-1", poll, found.Process)
+		}
+		if found.cached != (poll == "cached") {
+			t.Errorf("%s poll: process cached = %t", poll, found.cached)
+		}
+
+		if unresolved.Process != nil && unresolved.Process.Pid != 0 {
+			t.Errorf("%s poll: unresolved connection has agent process %+v", poll, unresolved.Process)
+		}
+		if detail := unresolved.Detail(); detail != "No connection found on proxy" {
+			t.Errorf("%s poll: unresolved connection detail = %q", poll, detail)
+		}
+	}
+}
+
+func TestFetchAllSourcesFail(t *testing.T) {
+	f := newTestFetcher([]Source{
+		&fakeSource{name: "db1", err: errors.New("connection refused")},
+		&fakeSource{name: "db2", err: errors.New("access denied")},
+	}, nil)
+	if _, err := f.FetchAll(context.Background()); err == nil {
+		t.Fatal("FetchAll() error = nil, want error if none of the sources could be fetched")
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/cenkalti/log"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -22,8 +23,16 @@ func (s *Server) Procs(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Access-Control-Allow-Headers", "access-control-allow-origin, access-control-allow-headers")
 	w.Header().Set("Content-Type", "application/json")
 
+	kps := s.GetProcesses()
+	if target := req.URL.Query().Get("target"); target != "" {
+		kps = filterByTarget(kps, target)
+	}
+	if tags := req.URL.Query()["tag"]; len(tags) > 0 {
+		kps = filterByTags(kps, tags)
+	}
+
 	response := &Response{
-		Processes: s.GetProcesses(),
+		Processes: kps,
 	}
 	err := json.NewEncoder(w).Encode(response)
 	if err != nil {
@@ -32,6 +41,40 @@ func (s *Server) Procs(w http.ResponseWriter, req *http.Request) {
 
 }
 
+// filterByTarget returns processes those are fetched from given mysql target.
+func filterByTarget(kps []KimoProcess, target string) []KimoProcess {
+	filtered := make([]KimoProcess, 0)
+	for _, kp := range kps {
+		if kp.Target == target {
+			filtered = append(filtered, kp)
+		}
+	}
+	return filtered
+}
+
+// filterByTags returns processes whose query tags match all of given "key=value" filters.
+// A filter without value matches processes those have the key.
+func filterByTags(kps []KimoProcess, tags []string) []KimoProcess {
+	filtered := make([]KimoProcess, 0)
+	for _, kp := range kps {
+		if matchTags(kp.Tags, tags) {
+			filtered = append(filtered, kp)
+		}
+	}
+	return filtered
+}
+
+func matchTags(kpTags map[string]string, tags []string) bool {
+	for _, tag := range tags {
+		key, value, hasValue := strings.Cut(tag, "=")
+		v, ok := kpTags[key]
+		if !ok || (hasValue && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
 // Static serves static files (web components).
 func (s *Server) Static() http.Handler {
 	statikFS, err := fs.New()
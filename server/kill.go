@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"kimo/auth"
+	"kimo/config"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/log"
+)
+
+// Kill modes
+const (
+	KillQuery      = "query"
+	KillConnection = "connection"
+)
+
+var errAmbiguousProcess = errors.New("process exists on more than one target, target param is required")
+
+// killUser is a user of the kill endpoint with the hash of its token.
+type killUser struct {
+	name string
+	hash [sha256.Size]byte
+}
+
+// loadKillUsers loads the tokens of given kill users. Users those tokens can not be loaded are skipped.
+func loadKillUsers(users []config.KillUser) []killUser {
+	var kus []killUser
+	for _, u := range users {
+		token, err := auth.LoadToken(u.TokenFile, u.TokenEnv)
+		switch {
+		case err != nil:
+		case u.Name == "":
+			err = errors.New("name is required")
+		case token == "":
+			err = errors.New("token_file or token_env is required")
+		}
+		if err != nil {
+			log.Errorf("Can not load kill token of %s: %s\n", u.Name, err)
+			continue
+		}
+		kus = append(kus, killUser{name: u.Name, hash: sha256.Sum256([]byte(token))})
+	}
+	return kus
+}
+
+// Kill is a handler for killing the query or the connection of a mysql process.
+// The process must exist in the latest poll. If more than one target has a process
+// with the same id, target param is required.
+// It is registered only if kill users are configured and the server has TLS.
+func (s *Server) Kill(w http.ResponseWriter, req *http.Request) {
+	// Browsers send cross-site form posts with the Origin header, but they can not set Authorization header.
+	if !sameOrigin(req) {
+		log.Debugf("Cross-site kill request from %s with origin %s\n", req.RemoteAddr, req.Header.Get("Origin"))
+		http.Error(w, "Cross-site requests are not allowed", http.StatusForbidden)
+		return
+	}
+	user, ok := s.authenticateKill(req)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kimo"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid process id", http.StatusBadRequest)
+		return
+	}
+
+	mode := req.URL.Query().Get("mode")
+	if mode == "" {
+		mode = KillQuery
+	}
+	if mode != KillQuery && mode != KillConnection {
+		http.Error(w, "mode must be either query or connection", http.StatusBadRequest)
+		return
+	}
+
+	kp, err := s.findKillable(int32(id), req.URL.Query().Get("target"))
+	if errors.Is(err, errAmbiguousProcess) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	killer, ok := s.Fetcher.Source(kp.Target).(Killer)
+	if !ok {
+		http.Error(w, "Kill is supported for mysql targets only", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 3*time.Second)
+	defer cancel()
+
+	log.Infof("%s is killing %s of process %d on %s (host: %s, pid: %d, cmdline: %s)\n",
+		user, mode, kp.ID, kp.Target, kp.Host, kp.Pid, kp.CmdLine)
+	err = killer.Kill(ctx, kp.ID, mode)
+	if err != nil {
+		log.Errorf("Kill %s of process %d on %s by %s failed: %s\n", mode, kp.ID, kp.Target, user, err)
+		http.Error(w, fmt.Sprintf("Can not kill process: %s", err), http.StatusInternalServerError)
+		return
+	}
+	log.Infof("%s killed %s of process %d on %s\n", user, mode, kp.ID, kp.Target)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticateKill checks the bearer token in Authorization header of the request and returns its user.
+// Unlike other endpoints, the token is not accepted as a query param, so that it can not be sent by a link or a form.
+// All tokens are compared in constant time regardless of a match.
+func (s *Server) authenticateKill(req *http.Request) (string, bool) {
+	token := auth.BearerToken(req.Header.Get("Authorization"))
+	if token == "" {
+		return "", false
+	}
+	h := sha256.Sum256([]byte(token))
+	var user string
+	for _, ku := range s.killUsers {
+		if subtle.ConstantTimeCompare(h[:], ku.hash[:]) == 1 {
+			user = ku.name
+		}
+	}
+	return user, user != ""
+}
+
+// sameOrigin returns true if the request has no Origin header or it is the origin of the server.
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+// findKillable finds the process with given id (and target) in the latest poll.
+func (s *Server) findKillable(id int32, target string) (*KimoProcess, error) {
+	var found []KimoProcess
+	for _, kp := range s.GetProcesses() {
+		if kp.ID != id {
+			continue
+		}
+		if target != "" && kp.Target != target {
+			continue
+		}
+		found = append(found, kp)
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("process %d not found in the latest poll", id)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, errAmbiguousProcess
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// LockNode is a process in the lock wait tree along with the processes waiting for it.
+type LockNode struct {
+	KimoProcess
+	LockedTable string      `json:"locked_table,omitempty"` // table that the process waits for its blocker on.
+	LockMode    string      `json:"lock_mode,omitempty"`    // mode of the lock held by the blocker.
+	Waiters     []*LockNode `json:"waiters,omitempty"`
+}
+
+// LocksResponse contains lock wait trees for API responses.
+type LocksResponse struct {
+	Locks []*LockNode `json:"locks"`
+}
+
+// Locks is a handler for returning blocker -> waiter trees of the latest poll.
+func (s *Server) Locks(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "access-control-allow-origin, access-control-allow-headers")
+	w.Header().Set("Content-Type", "application/json")
+
+	kps := s.GetProcesses()
+	if target := req.URL.Query().Get("target"); target != "" {
+		kps = filterByTarget(kps, target)
+	}
+
+	response := &LocksResponse{
+		Locks: buildLockTrees(kps),
+	}
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Can not encode locks", http.StatusInternalServerError)
+	}
+}
+
+// lockKey identifies a process across targets.
+type lockKey struct {
+	target string
+	id     int32
+}
+
+// buildLockTrees builds blocker -> waiter trees from lock waits of given processes.
+// Roots are the processes those block others without waiting for a lock themselves.
+// Blockers those are only part of a wait cycle become roots as well.
+func buildLockTrees(kps []KimoProcess) []*LockNode {
+	procs := make(map[lockKey]KimoProcess, len(kps))
+	for _, kp := range kps {
+		procs[lockKey{kp.Target, kp.ID}] = kp
+	}
+
+	waits := make(map[lockKey][]*LockWait) // blocker -> waits for its locks
+	waiting := make(map[lockKey]bool)
+	var blockers []lockKey
+	for _, kp := range kps {
+		for _, lw := range kp.BlockedBy {
+			blocker := lockKey{kp.Target, lw.BlockingID}
+			if _, ok := waits[blocker]; !ok {
+				blockers = append(blockers, blocker)
+			}
+			waits[blocker] = append(waits[blocker], lw)
+			waiting[lockKey{kp.Target, kp.ID}] = true
+		}
+	}
+
+	expanded := make(map[lockKey]bool)
+	onPath := make(map[lockKey]bool)
+	var build func(k lockKey, lw *LockWait) *LockNode
+	build = func(k lockKey, lw *LockWait) *LockNode {
+		kp, ok := procs[k]
+		if !ok {
+			kp = KimoProcess{Target: k.target, ID: k.id, Detail: "Process not found in the latest poll"}
+		}
+		node := &LockNode{KimoProcess: kp}
+		if lw != nil {
+			node.LockedTable = lw.LockedTable
+			node.LockMode = lw.LockMode
+		}
+		if onPath[k] { // wait cycle
+			return node
+		}
+		onPath[k] = true
+		expanded[k] = true
+		for _, w := range waits[k] {
+			node.Waiters = append(node.Waiters, build(lockKey{k.target, w.WaitingID}, w))
+		}
+		onPath[k] = false
+		return node
+	}
+
+	roots := make([]*LockNode, 0)
+	for _, blocker := range blockers {
+		if !waiting[blocker] {
+			roots = append(roots, build(blocker, nil))
+		}
+	}
+	for _, blocker := range blockers {
+		if !expanded[blocker] {
+			roots = append(roots, build(blocker, nil))
+		}
+	}
+	return roots
+}
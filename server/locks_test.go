@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// formatLockTrees formats lock trees as "target/id(waiter, ...)" for comparison.
+func formatLockTrees(nodes []*LockNode) string {
+	s := make([]string, len(nodes))
+	for i, n := range nodes {
+		s[i] = fmt.Sprintf("%s/%d", n.Target, n.ID)
+		if len(n.Waiters) > 0 {
+			s[i] += "(" + formatLockTrees(n.Waiters) + ")"
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// waitingProcess returns a process of target db1 that waits for given blockers.
+func waitingProcess(id int32, blockers ...int32) KimoProcess {
+	kp := KimoProcess{Target: "db1", ID: id}
+	for _, b := range blockers {
+		kp.BlockedBy = append(kp.BlockedBy, &LockWait{WaitingID: id, BlockingID: b, LockedTable: "`app`.`orders`", LockMode: "X"})
+	}
+	return kp
+}
+
+func TestBuildLockTrees(t *testing.T) {
+	tests := []struct {
+		name string
+		kps  []KimoProcess
+		want string
+	}{
+		{"no waits", []KimoProcess{waitingProcess(1), waitingProcess(2)}, ""},
+		{"single wait", []KimoProcess{waitingProcess(1), waitingProcess(2, 1)}, "db1/1(db1/2)"},
+		{"chain", []KimoProcess{waitingProcess(1), waitingProcess(2, 1), waitingProcess(3, 2)}, "db1/1(db1/2(db1/3))"},
+		{"two waiters", []KimoProcess{waitingProcess(1), waitingProcess(2, 1), waitingProcess(3, 1)}, "db1/1(db1/2, db1/3)"},
+		{"two blockers", []KimoProcess{waitingProcess(1), waitingProcess(2), waitingProcess(3, 1, 2)}, "db1/1(db1/3), db1/2(db1/3)"},
+		{"blocker not in poll", []KimoProcess{waitingProcess(2, 1)}, "db1/1(db1/2)"},
+		{"cycle", []KimoProcess{waitingProcess(1, 2), waitingProcess(2, 1)}, "db1/2(db1/1(db1/2))"},
+		{"cycle with a waiter", []KimoProcess{waitingProcess(1, 2), waitingProcess(2, 1), waitingProcess(3, 1)},
+			"db1/2(db1/1(db1/2, db1/3))"},
+		{"same ids on other targets", []KimoProcess{waitingProcess(1), waitingProcess(2, 1), {Target: "db2", ID: 2}},
+			"db1/1(db1/2)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLockTrees(buildLockTrees(tt.kps)); got != tt.want {
+				t.Errorf("buildLockTrees() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildLockTreesLockDetails(t *testing.T) {
+	trees := buildLockTrees([]KimoProcess{waitingProcess(2, 1)})
+	if len(trees) != 1 || len(trees[0].Waiters) != 1 {
+		t.Fatalf("buildLockTrees() = %q, want a single wait", formatLockTrees(trees))
+	}
+	if trees[0].Detail == "" {
+		t.Errorf("blocker that is not in the poll has no detail")
+	}
+	waiter := trees[0].Waiters[0]
+	if waiter.LockedTable != "`app`.`orders`" || waiter.LockMode != "X" {
+		t.Errorf("waiter lock = %s %s, want `app`.`orders` X", waiter.LockedTable, waiter.LockMode)
+	}
+}
@@ -2,8 +2,10 @@ package server
 
 import (
 	"fmt"
+	"kimo/config"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/cenkalti/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,35 +14,117 @@ import (
 
 // PrometheusMetric represents the type that contains all metrics those will be exposed.
 type PrometheusMetric struct {
-	conns prometheus.Gauge
+	conns *prometheus.GaugeVec
 	conn  *prometheus.GaugeVec
 
+	trxs            *prometheus.GaugeVec
+	trxMaxAge       *prometheus.GaugeVec
+	trxRowsLocked   *prometheus.GaugeVec
+	trxRowsModified *prometheus.GaugeVec
+
+	agentUp              *prometheus.GaugeVec
+	agentRequestDuration *prometheus.HistogramVec
+	agentErrors          *prometheus.CounterVec
+
 	cmdlineRegexps []*regexp.Regexp
+	tagLabels      map[string]string // query tag key -> label name
 }
 
 // NewPrometheusMetric creates and returns a new PrometheusMetric.
-func NewPrometheusMetric(cmdlinePatterns []string) *PrometheusMetric {
+func NewPrometheusMetric(cfg config.Metric) *PrometheusMetric {
+	tagLabels := convertTagsToLabels(cfg.TagLabels)
+	connLabelNames := []string{
+		"target",
+		"db",
+		"host",
+		"command",
+		"state",
+		"cmdline",
+	}
+	for _, label := range tagLabels {
+		connLabelNames = append(connLabelNames, label)
+	}
 	return &PrometheusMetric{
-		cmdlineRegexps: convertPatternsToRegexps(cmdlinePatterns),
-		conns: promauto.NewGauge(prometheus.GaugeOpts{
+		cmdlineRegexps: convertPatternsToRegexps(cfg.CmdlinePatterns),
+		tagLabels:      tagLabels,
+		conns: promauto.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "kimo_mysql_conns_total",
 			Help: "Total number of db processes (conns)",
-		}),
+		},
+			[]string{
+				"target",
+			},
+		),
 		conn: promauto.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "kimo_mysql_connection",
 			Help: "Kimo mysql connection.",
+		},
+			connLabelNames,
+		),
+		trxs: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kimo_mysql_trx_total",
+			Help: "Number of open InnoDB transactions.",
+		},
+			trxLabelNames,
+		),
+		trxMaxAge: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kimo_mysql_trx_max_age_seconds",
+			Help: "Seconds passed since the oldest open InnoDB transaction is started.",
+		},
+			trxLabelNames,
+		),
+		trxRowsLocked: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kimo_mysql_trx_rows_locked",
+			Help: "Approximate number of rows locked by open InnoDB transactions.",
+		},
+			trxLabelNames,
+		),
+		trxRowsModified: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kimo_mysql_trx_rows_modified",
+			Help: "Number of rows modified by open InnoDB transactions.",
+		},
+			trxLabelNames,
+		),
+		agentUp: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kimo_agent_up",
+			Help: "Whether the last poll of the agent is successful.",
 		},
 			[]string{
-				"db",
+				"ip",
 				"host",
-				"command",
-				"state",
-				"cmdline",
+				"version",
+				"mode",
+			},
+		),
+		agentRequestDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "kimo_agent_request_duration_seconds",
+			Help: "Duration of requests to agents, including retries.",
+		},
+			[]string{
+				"ip",
+			},
+		),
+		agentErrors: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "kimo_agent_request_errors_total",
+			Help: "Number of failed requests to agents by reason (timeout, connection or response).",
+		},
+			[]string{
+				"ip",
+				"reason",
 			},
 		),
 	}
 }
 
+// trxLabelNames are the labels of the transaction metrics those are aggregated over connections.
+// Transactions of single connections are listed in /procs, connection ids would make unbounded series.
+var trxLabelNames = []string{
+	"target",
+	"host",
+	"trx_state",
+	"cmdline",
+}
+
 // convertPatternsToRegexps converts given patterns into regexps.
 func convertPatternsToRegexps(patterns []string) []*regexp.Regexp {
 	rps := make([]*regexp.Regexp, 0)
@@ -51,24 +135,103 @@ func convertPatternsToRegexps(patterns []string) []*regexp.Regexp {
 
 }
 
+// convertTagsToLabels maps given query tag keys to valid and unique prometheus label names.
+func convertTagsToLabels(keys []string) map[string]string {
+	labels := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		label := "tag_" + invalidLabelCharRegexp.ReplaceAllString(key, "_")
+		if seen[label] {
+			log.Errorf("Tag %s is skipped, label %s is already used\n", key, label)
+			continue
+		}
+		seen[label] = true
+		labels[key] = label
+	}
+	return labels
+}
+
+var invalidLabelCharRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
 // Set sets all metrics based on Processes
 func (pm *PrometheusMetric) Set(kps []KimoProcess) {
 	// clear previous run.
-	pm.conns.Set(0)
+	pm.conns.MetricVec.Reset()
 	pm.conn.MetricVec.Reset()
+	pm.trxs.MetricVec.Reset()
+	pm.trxMaxAge.MetricVec.Reset()
+	pm.trxRowsLocked.MetricVec.Reset()
+	pm.trxRowsModified.MetricVec.Reset()
 
 	log.Debugf("Found '%d' processes. Setting metrics...\n", len(kps))
 
-	pm.conns.Set(float64(len(kps)))
-
+	maxAges := make(map[trxKey]uint32)
 	for _, p := range kps {
-		pm.conn.With(prometheus.Labels{
+		pm.conns.With(prometheus.Labels{"target": p.Target}).Inc()
+		connLabels := prometheus.Labels{
+			"target":  p.Target,
 			"db":      p.DB,
 			"host":    p.Host,
 			"command": p.Command,
 			"state":   p.State,
 			"cmdline": pm.formatCmdline(p.CmdLine),
-		}).Inc()
+		}
+		for key, label := range pm.tagLabels {
+			connLabels[label] = p.Tags[key]
+		}
+		pm.conn.With(connLabels).Inc()
+
+		if p.TrxState != "" {
+			labels := prometheus.Labels{
+				"target":    p.Target,
+				"host":      p.Host,
+				"trx_state": p.TrxState,
+				"cmdline":   pm.formatCmdline(p.CmdLine),
+			}
+			pm.trxs.With(labels).Inc()
+			pm.trxRowsLocked.With(labels).Add(float64(p.TrxRowsLocked))
+			pm.trxRowsModified.With(labels).Add(float64(p.TrxRowsModified))
+			key := trxKey{p.Target, p.Host, p.TrxState, labels["cmdline"]}
+			maxAges[key] = max(maxAges[key], p.TrxAge)
+		}
+	}
+	for key, age := range maxAges {
+		pm.trxMaxAge.With(prometheus.Labels{
+			"target":    key.target,
+			"host":      key.host,
+			"trx_state": key.state,
+			"cmdline":   key.cmdline,
+		}).Set(float64(age))
+	}
+}
+
+// trxKey is the label values of a transaction metric.
+type trxKey struct {
+	target, host, state, cmdline string
+}
+
+// SetAgents sets agent metrics based on statuses of agents.
+func (pm *PrometheusMetric) SetAgents(statuses []AgentStatus) {
+	pm.agentUp.MetricVec.Reset()
+	for _, st := range statuses {
+		up := 0.0
+		if st.Up {
+			up = 1
+		}
+		pm.agentUp.With(prometheus.Labels{
+			"ip":      st.IP,
+			"host":    st.Hostname,
+			"version": st.Version,
+			"mode":    st.Mode,
+		}).Set(up)
+	}
+}
+
+// ObserveAgentRequest observes the duration and error of a request to the agent with given IP.
+func (pm *PrometheusMetric) ObserveAgentRequest(ip string, d time.Duration, err error) {
+	pm.agentRequestDuration.With(prometheus.Labels{"ip": ip}).Observe(d.Seconds())
+	if err != nil {
+		pm.agentErrors.With(prometheus.Labels{"ip": ip, "reason": agentErrorReason(err)}).Inc()
 	}
 }
 
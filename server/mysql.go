@@ -3,56 +3,486 @@ package server
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"kimo/config"
+	"net"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cenkalti/log"
-	_ "github.com/go-sql-driver/mysql" // imports mysql driver
+	"github.com/go-sql-driver/mysql"
 )
 
 // MysqlRow represents a row from processlist table
 type MysqlRow struct {
 	ID      int32          `json:"id"`
 	User    string         `json:"user"`
+	Host    string         `json:"host"` // client host without port as mysql reports.
 	DB      sql.NullString `json:"db"`
 	Command string         `json:"command"`
 	Time    string         `json:"time"`
 	State   sql.NullString `json:"state"`
 	Info    sql.NullString `json:"info"`
 	Address IPPort         `json:"address"`
+	Fd      int32          `json:"fd"`     // fd of the unix socket connection in mysqld, 0 for TCP connections.
+	Server  IPPort         `json:"server"` // mysql server address that the client is connected to, zero value if unknown.
+
+	// Only available when rows are read from performance_schema.
+	ThreadID       sql.NullInt64  `json:"thread_id"`
+	ConnectionType sql.NullString `json:"connection_type"`
+	ResourceGroup  sql.NullString `json:"resource_group"`
+
+	Trx       *InnodbTrx  `json:"trx"`        // open InnoDB transaction of the connection, if any.
+	LockWaits []*LockWait `json:"lock_waits"` // locks that the connection waits for.
+}
+
+// LockWait represents a connection waiting for a lock held by another connection
+type LockWait struct {
+	WaitingID   int32  `json:"waiting_id"`
+	BlockingID  int32  `json:"blocking_id"`
+	LockedTable string `json:"locked_table"`
+	LockMode    string `json:"lock_mode"`
+}
+
+// InnodbTrx represents a row from INNODB_TRX table
+type InnodbTrx struct {
+	ThreadID       int32     `json:"thread_id"`
+	State          string    `json:"state"`
+	Started        time.Time `json:"started"`
+	Age            uint32    `json:"age"` // seconds passed since the transaction is started.
+	RowsLocked     uint64    `json:"rows_locked"`
+	RowsModified   uint64    `json:"rows_modified"`
+	IsolationLevel string    `json:"isolation_level"`
+}
+
+func init() {
+	RegisterSource("mysql", func(cfg config.ServerConfig) []Source {
+		var sources []Source
+		for _, mc := range cfg.MySQL {
+			sources = append(sources, NewMysqlClient(mc))
+		}
+		return sources
+	})
+}
+
+// ClientAddress returns the client address of the row.
+func (mr *MysqlRow) ClientAddress() IPPort {
+	return mr.Address
+}
+
+// SocketFD returns fd of the unix socket connection in mysqld.
+func (mr *MysqlRow) SocketFD() int32 {
+	return mr.Fd
+}
+
+// ServerAddress returns the mysql server address that the client is connected to.
+func (mr *MysqlRow) ServerAddress() IPPort {
+	return mr.Server
+}
+
+// ConnectionID returns the mysql process id of the connection.
+func (mr *MysqlRow) ConnectionID() int32 {
+	return mr.ID
+}
+
+// Fill sets mysql properties of given kimo process.
+func (mr *MysqlRow) Fill(kp *KimoProcess) {
+	ut, err := strconv.ParseUint(mr.Time, 10, 32)
+	if err != nil {
+		log.Errorf("time %s could not be converted to int", mr.Time)
+	}
+
+	kp.ID = mr.ID
+	kp.DBUser = mr.User
+	kp.DB = mr.DB.String
+	kp.Command = mr.Command
+	kp.Time = uint32(ut)
+	kp.State = mr.State.String
+	kp.Info = mr.Info.String
+	kp.ClientHost = mr.Host
+	kp.ThreadID = mr.ThreadID.Int64
+	kp.ConnectionType = mr.ConnectionType.String
+	kp.ResourceGroup = mr.ResourceGroup.String
+
+	if mr.Trx != nil {
+		started := mr.Trx.Started
+		kp.TrxState = mr.Trx.State
+		kp.TrxStarted = &started
+		kp.TrxAge = mr.Trx.Age
+		kp.TrxRowsLocked = mr.Trx.RowsLocked
+		kp.TrxRowsModified = mr.Trx.RowsModified
+		kp.TrxIsolationLevel = mr.Trx.IsolationLevel
+	}
+	kp.BlockedBy = mr.LockWaits
 }
 
 // NewMysqlClient creates and returns a new *MysqlClient.
 func NewMysqlClient(cfg config.MySQLConfig) *MysqlClient {
 	m := new(MysqlClient)
+	m.name = cfg.Name
+	if m.name == "" {
+		m.name = dsnAddress(cfg.DSN)
+	}
 	m.DSN = cfg.DSN
+	m.PerformanceSchema = cfg.PerformanceSchema
+	m.localIP = dsnHost(cfg.DSN)
+
+	// sql.Open does not connect, it only validates the DSN.
+	// Connections are opened lazily and reused between polls.
+	m.db, m.dbErr = sql.Open("mysql", cfg.DSN)
+	if m.dbErr != nil {
+		log.Errorf("Can not open mysql target %s: %s\n", m.name, m.dbErr)
+		return m
+	}
+	m.db.SetMaxOpenConns(valueOrDefault(cfg.MaxOpenConns, 1))
+	m.db.SetMaxIdleConns(valueOrDefault(cfg.MaxIdleConns, 1))
+	m.db.SetConnMaxLifetime(valueOrDefault(cfg.ConnMaxLifetime, 5*time.Minute))
 	return m
 }
 
+// valueOrDefault returns given default value if value is not set.
+func valueOrDefault[T int | time.Duration](value, defaultValue T) T {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// dsnAddress returns the server address in given DSN to be used as the default target name.
+func dsnAddress(dsn string) string {
+	c, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		log.Errorf("Can not parse mysql dsn: %s\n", err)
+		return ""
+	}
+	return c.Addr
+}
+
+// dsnServerAddress returns the resolved TCP address of the mysql server in given DSN, zero value if it is unknown.
+func dsnServerAddress(dsn string) IPPort {
+	c, err := mysql.ParseDSN(dsn)
+	if err != nil || c.Net == "unix" {
+		return IPPort{}
+	}
+	host, port, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return IPPort{}
+	}
+	ip, err := findHostIP(host)
+	if err != nil {
+		log.Debugf("Can not resolve mysql server host %s: %s\n", host, err)
+		return IPPort{}
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return IPPort{}
+	}
+	return IPPort{IP: ip, Port: uint32(p)}
+}
+
+// dsnHost returns the host of the mysql server in given DSN.
+// Connections from the mysql server host itself (unix socket or loopback) are resolved by the agent on this host.
+func dsnHost(dsn string) string {
+	c, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return ""
+	}
+	if c.Net == "unix" {
+		return "127.0.0.1"
+	}
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return normalizeIP(c.Addr)
+	}
+	return normalizeIP(host)
+}
+
 // MysqlClient represents a MySQL database client that manages connection details and stores query results.
 type MysqlClient struct {
-	DSN       string
-	MysqlRows []MysqlRow
+	DSN               string
+	PerformanceSchema bool // read rows from performance_schema.threads instead of information_schema.PROCESSLIST
+	MysqlRows         []MysqlRow
+
+	name    string  // name of the target, used to tag processes.
+	localIP string  // address of the mysql server host, used for local connections.
+	db      *sql.DB // long-lived connection pool.
+	dbErr   error   // error occurred while opening db.
+
+	serverMu       sync.Mutex
+	server         IPPort // resolved address of the mysql server, see serverAddress.
+	serverResolved time.Time
 }
 
-// Get gets  processlist table from information_schema.
+// Name returns the target name of the client.
+func (mc *MysqlClient) Name() string {
+	return mc.name
+}
+
+// Fetch fetches processlist rows as generic rows.
+func (mc *MysqlClient) Fetch(ctx context.Context) ([]Row, error) {
+	mrs, err := mc.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, len(mrs))
+	for i, mr := range mrs {
+		rows[i] = mr
+	}
+	return rows, nil
+}
+
+// Get gets processlist rows from information_schema or performance_schema.
 func (mc *MysqlClient) Get(ctx context.Context) ([]*MysqlRow, error) {
-	db, err := sql.Open("mysql", mc.DSN)
+	if mc.dbErr != nil {
+		return nil, mc.dbErr
+	}
+
+	err := mc.db.PingContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ping: %w", err)
+	}
+
+	// All queries run on the same connection so that it can be filtered out from the results.
+	conn, err := mc.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var ownID int32
+	err = conn.QueryRowContext(ctx, "select CONNECTION_ID()").Scan(&ownID)
+	if err != nil {
+		return nil, err
+	}
+
+	var mps []*MysqlRow
+	if mc.PerformanceSchema {
+		mps, err = getThreads(ctx, conn)
+	} else {
+		mps, err = getProcesslist(ctx, conn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	mps = excludeConnection(mps, ownID)
+
+	if !mc.PerformanceSchema && hasUnixSocketRows(mps) {
+		fds, err := getSocketFDs(ctx, conn)
+		if err != nil {
+			log.Errorf("Can not get unix socket fds: %s\n", err)
+		} else {
+			addSocketFDs(mps, fds)
+		}
+	}
+	mc.localizeRows(mps)
+
+	// Transactions and locks are nice to have, do not fail the whole fetch because of them.
+	trxs, err := getInnodbTrxs(ctx, conn)
+	if err != nil {
+		log.Errorf("Can not get innodb transactions: %s\n", err)
+	} else {
+		addInnodbTrxs(mps, trxs)
+	}
+
+	waits, err := getLockWaits(ctx, conn)
+	if err != nil {
+		log.Errorf("Can not get lock waits: %s\n", err)
+	} else {
+		addLockWaits(mps, waits)
+	}
+	return mps, nil
+}
+
+// hasUnixSocketRows returns true if one of the rows is a unix socket connection.
+func hasUnixSocketRows(mps []*MysqlRow) bool {
+	for _, mp := range mps {
+		if mp.Address.Port == 0 {
+			return true
+		}
+	}
+	return false
+}
 
+// getSocketFDs gets fds of unix socket connections in mysqld from performance_schema, keyed by processlist id.
+func getSocketFDs(ctx context.Context, conn *sql.Conn) (map[int32]int32, error) {
+	results, err := conn.QueryContext(ctx, `select t.PROCESSLIST_ID, s.SOCKET_ID
+		from performance_schema.socket_instances s
+		join performance_schema.threads t on t.THREAD_ID = s.THREAD_ID
+		where s.PORT = 0 and t.PROCESSLIST_ID is not null`)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
+	defer results.Close()
+
+	fds := make(map[int32]int32)
+	for results.Next() {
+		var id, fd int32
+		err = results.Scan(&id, &fd)
+		if err != nil {
+			return nil, err
+		}
+		fds[id] = fd
+	}
+	return fds, results.Err()
+}
+
+// addSocketFDs adds fds to unix socket connection rows.
+func addSocketFDs(mps []*MysqlRow, fds map[int32]int32) {
+	for _, mp := range mps {
+		if fd, ok := fds[mp.ID]; ok && mp.Address.Port == 0 {
+			mp.Fd = fd
+		}
+	}
+}
+
+// localizeRows replaces client addresses of local connections (unix socket or loopback) with the mysql server host
+// and sets the server address of remote connections.
+func (mc *MysqlClient) localizeRows(mps []*MysqlRow) {
+	server := mc.serverAddress()
+	for _, mp := range mps {
+		if mp.Address.Port == 0 || isLoopback(mp.Address.IP) {
+			mp.Address.IP = mc.localIP
+		} else {
+			mp.Server = server
+		}
+	}
+}
+
+// serverAddressTTL is the duration that the resolved address of the mysql server is reused.
+const serverAddressTTL = 5 * time.Minute
+
+// serverAddress returns the resolved address of the mysql server in the DSN.
+// It is resolved again after serverAddressTTL, or on next poll if it could not be resolved.
+func (mc *MysqlClient) serverAddress() IPPort {
+	mc.serverMu.Lock()
+	defer mc.serverMu.Unlock()
+	if mc.server.IP == "" || time.Since(mc.serverResolved) > serverAddressTTL {
+		mc.server = dsnServerAddress(mc.DSN)
+		mc.serverResolved = time.Now()
+	}
+	return mc.server
+}
+
+// isLoopback returns true if given host is localhost or a loopback IP.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// excludeConnection removes the row of the connection with given id.
+func excludeConnection(mps []*MysqlRow, id int32) []*MysqlRow {
+	filtered := make([]*MysqlRow, 0, len(mps))
+	for _, mp := range mps {
+		if mp.ID != id {
+			filtered = append(filtered, mp)
+		}
+	}
+	return filtered
+}
+
+// getLockWaits gets lock waits from performance_schema.data_lock_waits (MySQL 8.0+).
+// It falls back to sys.innodb_lock_waits on older versions.
+func getLockWaits(ctx context.Context, conn *sql.Conn) ([]*LockWait, error) {
+	waits, err := queryLockWaits(ctx, conn, `select r.PROCESSLIST_ID, b.PROCESSLIST_ID,
+		concat(l.OBJECT_SCHEMA, '.', l.OBJECT_NAME), l.LOCK_MODE
+		from performance_schema.data_lock_waits w
+		join performance_schema.threads r on r.THREAD_ID = w.REQUESTING_THREAD_ID
+		join performance_schema.threads b on b.THREAD_ID = w.BLOCKING_THREAD_ID
+		join performance_schema.data_locks l on l.ENGINE_LOCK_ID = w.BLOCKING_ENGINE_LOCK_ID`)
+	if err != nil {
+		log.Debugf("Can not read data_lock_waits, falling back to sys.innodb_lock_waits: %s\n", err)
+		return queryLockWaits(ctx, conn, `select waiting_pid, blocking_pid, locked_table, blocking_lock_mode
+			from sys.innodb_lock_waits`)
+	}
+	return waits, nil
+}
 
-	results, err := db.QueryContext(ctx, "select * from PROCESSLIST")
+// queryLockWaits runs given lock wait query which selects waiting id, blocking id, locked table and lock mode.
+func queryLockWaits(ctx context.Context, conn *sql.Conn, query string) ([]*LockWait, error) {
+	results, err := conn.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	defer results.Close()
 
-	if results == nil {
+	waits := make([]*LockWait, 0)
+	for results.Next() {
+		var w LockWait
+		var table, mode sql.NullString
+		err = results.Scan(&w.WaitingID, &w.BlockingID, &table, &mode)
+		if err != nil {
+			return nil, err
+		}
+		w.LockedTable = table.String
+		w.LockMode = mode.String
+		waits = append(waits, &w)
+	}
+	return waits, results.Err()
+}
+
+// addLockWaits adds lock waits to the rows of waiting connections.
+func addLockWaits(mps []*MysqlRow, waits []*LockWait) {
+	byID := make(map[int32]*MysqlRow, len(mps))
+	for _, mp := range mps {
+		byID[mp.ID] = mp
+	}
+	for _, w := range waits {
+		if mp, ok := byID[w.WaitingID]; ok {
+			mp.LockWaits = append(mp.LockWaits, w)
+		}
+	}
+}
+
+// getInnodbTrxs gets open transactions from information_schema.INNODB_TRX table.
+func getInnodbTrxs(ctx context.Context, conn *sql.Conn) ([]*InnodbTrx, error) {
+	results, err := conn.QueryContext(ctx, `select trx_mysql_thread_id, trx_state, UNIX_TIMESTAMP(trx_started),
+		TIMESTAMPDIFF(SECOND, trx_started, NOW()), trx_rows_locked, trx_rows_modified, trx_isolation_level
+		from information_schema.INNODB_TRX`)
+	if err != nil {
 		return nil, err
 	}
+	defer results.Close()
+
+	trxs := make([]*InnodbTrx, 0)
+	for results.Next() {
+		var trx InnodbTrx
+		var started int64
+		err = results.Scan(&trx.ThreadID, &trx.State, &started, &trx.Age, &trx.RowsLocked, &trx.RowsModified, &trx.IsolationLevel)
+		if err != nil {
+			return nil, err
+		}
+		trx.Started = time.Unix(started, 0)
+		trxs = append(trxs, &trx)
+	}
+	return trxs, results.Err()
+}
+
+// addInnodbTrxs adds transactions to the rows of their connections.
+func addInnodbTrxs(mps []*MysqlRow, trxs []*InnodbTrx) {
+	byThreadID := make(map[int32]*InnodbTrx, len(trxs))
+	for _, trx := range trxs {
+		byThreadID[trx.ThreadID] = trx
+	}
+	for _, mp := range mps {
+		if trx, ok := byThreadID[mp.ID]; ok {
+			mp.Trx = trx
+		}
+	}
+}
+
+// getProcesslist gets rows from information_schema.PROCESSLIST table.
+func getProcesslist(ctx context.Context, conn *sql.Conn) ([]*MysqlRow, error) {
+	results, err := conn.QueryContext(ctx, `select ID, USER, HOST, DB, COMMAND, TIME, STATE, INFO
+		from information_schema.PROCESSLIST`)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
 
 	mps := make([]*MysqlRow, 0)
 	for results.Next() {
@@ -64,21 +494,98 @@ func (mc *MysqlClient) Get(ctx context.Context) ([]*MysqlRow, error) {
 		if err != nil {
 			return nil, err
 		}
-		s := strings.Split(host, ":")
-		if len(s) < 2 {
-			// it might be localhost
+		if mp.Command == "Daemon" {
+			// server thread without a client (e.g. event_scheduler), its host is localhost too.
 			continue
 		}
-		parsedPort, err := strconv.ParseInt(s[1], 10, 32)
+		if host == "localhost" {
+			// unix socket connection, client address is set later.
+			mp.Host = host
+			mps = append(mps, &mp)
+			continue
+		}
+		if !strings.Contains(host, ":") {
+			// it might be a system thread (e.g. event_scheduler) without a client.
+			continue
+		}
+		addr, err := parseHostPort(host)
 		if err != nil {
-			log.Errorf("error during string to int32: %s\n", err)
+			log.Errorf("Can not parse host of process %d: %s\n", mp.ID, err)
 			continue
 		}
+		mp.Host = addr.IP
+		mp.Address = addr
+		mps = append(mps, &mp)
+	}
+	return mps, results.Err()
+}
+
+// getThreads gets foreground threads from performance_schema.threads table.
+// It does not take the global processlist mutex. Client address is read from socket_instances
+// because PROCESSLIST_HOST does not contain the port. For unix socket connections, fd of the socket is read.
+func getThreads(ctx context.Context, conn *sql.Conn) ([]*MysqlRow, error) {
+	results, err := conn.QueryContext(ctx, `select t.PROCESSLIST_ID, t.THREAD_ID, t.PROCESSLIST_USER, t.PROCESSLIST_HOST,
+		t.PROCESSLIST_DB, t.PROCESSLIST_COMMAND, t.PROCESSLIST_TIME, t.PROCESSLIST_STATE, t.PROCESSLIST_INFO,
+		t.CONNECTION_TYPE, t.RESOURCE_GROUP, s.IP, s.PORT, s.SOCKET_ID
+		from performance_schema.threads t
+		left join performance_schema.socket_instances s on s.THREAD_ID = t.THREAD_ID
+		where t.TYPE = 'FOREGROUND' and t.PROCESSLIST_ID is not null`)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	mps := make([]*MysqlRow, 0)
+	for results.Next() {
+		var mp MysqlRow
+		var user, host, command, t, ip sql.NullString
+		var port, fd sql.NullInt64
+
+		err = results.Scan(&mp.ID, &mp.ThreadID, &user, &host, &mp.DB, &command, &t, &mp.State, &mp.Info,
+			&mp.ConnectionType, &mp.ResourceGroup, &ip, &port, &fd)
+		if err != nil {
+			return nil, err
+		}
+		if !port.Valid {
+			// no socket, it might be a thread without a client.
+			continue
+		}
+		if port.Int64 == 0 {
+			// unix socket connection, client address is set later.
+			mp.Fd = int32(fd.Int64)
+		}
+		mp.User = user.String
+		mp.Host = host.String
+		mp.Command = command.String
+		mp.Time = t.String
+		if !t.Valid {
+			mp.Time = "0"
+		}
 		mp.Address = IPPort{
-			IP:   s[0],
-			Port: uint32(parsedPort),
+			IP:   normalizeIP(ip.String),
+			Port: uint32(port.Int64),
 		}
 		mps = append(mps, &mp)
 	}
-	return mps, nil
+	return mps, results.Err()
+}
+
+// Kill kills the query or the connection of the process with given id.
+func (mc *MysqlClient) Kill(ctx context.Context, id int32, mode string) error {
+	var stmt string
+	switch mode {
+	case KillQuery:
+		stmt = fmt.Sprintf("KILL QUERY %d", id)
+	case KillConnection:
+		stmt = fmt.Sprintf("KILL CONNECTION %d", id)
+	default:
+		return fmt.Errorf("invalid kill mode: %s", mode)
+	}
+
+	if mc.dbErr != nil {
+		return mc.dbErr
+	}
+
+	_, err := mc.db.ExecContext(ctx, stmt)
+	return err
 }
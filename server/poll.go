@@ -69,6 +69,7 @@ func (s *Server) doPoll(ctx context.Context) error {
 		kps := s.ConvertProcesses(r.rps)
 		s.SetProcesses(kps)
 		s.PrometheusMetric.Set(s.GetProcesses())
+		s.PrometheusMetric.SetAgents(s.Fetcher.Statuses.List())
 		s.UpdateHealth(nil)
 		log.Debugf("%d processes are set\n", len(s.GetProcesses()))
 		return nil
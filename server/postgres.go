@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"kimo/config"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/log"
+	_ "github.com/lib/pq" // imports postgresql driver
+)
+
+// PostgresRow represents a row from pg_stat_activity view
+type PostgresRow struct {
+	Pid          int32          `json:"pid"`
+	User         sql.NullString `json:"user"`
+	DB           sql.NullString `json:"db"`
+	State        sql.NullString `json:"state"`
+	Query        sql.NullString `json:"query"`
+	BackendStart time.Time      `json:"backend_start"`
+	StateTime    int64          `json:"state_time"` // seconds passed since the state is changed
+	Address      IPPort         `json:"address"`
+}
+
+func init() {
+	RegisterSource("postgresql", func(cfg config.ServerConfig) []Source {
+		var sources []Source
+		for _, pc := range cfg.PostgreSQL {
+			sources = append(sources, NewPostgresClient(pc))
+		}
+		return sources
+	})
+}
+
+// ClientAddress returns the client address of the row.
+func (pr *PostgresRow) ClientAddress() IPPort {
+	return pr.Address
+}
+
+// ConnectionID returns the backend pid of the connection.
+func (pr *PostgresRow) ConnectionID() int32 {
+	return pr.Pid
+}
+
+// Fill sets postgresql properties of given kimo process.
+// ID is the backend pid and Time is the seconds passed in the current state, like mysql.
+func (pr *PostgresRow) Fill(kp *KimoProcess) {
+	kp.ID = pr.Pid
+	kp.DBUser = pr.User.String
+	kp.DB = pr.DB.String
+	kp.Time = uint32(pr.StateTime)
+	backendStart := pr.BackendStart
+	kp.BackendStart = &backendStart
+	kp.State = pr.State.String
+	kp.Info = pr.Query.String
+}
+
+// PostgresClient represents a PostgreSQL database client that manages connection details.
+type PostgresClient struct {
+	DSN string
+
+	name  string  // name of the target, used to tag processes.
+	db    *sql.DB // long-lived connection pool.
+	dbErr error   // error occurred while opening db.
+}
+
+// Name returns the target name of the client.
+func (pc *PostgresClient) Name() string {
+	return pc.name
+}
+
+// Fetch fetches pg_stat_activity rows as generic rows.
+func (pc *PostgresClient) Fetch(ctx context.Context) ([]Row, error) {
+	prs, err := pc.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, len(prs))
+	for i, pr := range prs {
+		rows[i] = pr
+	}
+	return rows, nil
+}
+
+// NewPostgresClient creates and returns a new *PostgresClient.
+func NewPostgresClient(cfg config.PostgreSQLConfig) *PostgresClient {
+	pc := new(PostgresClient)
+	pc.name = cfg.Name
+	if pc.name == "" {
+		pc.name = pgDSNAddress(cfg.DSN)
+	}
+	pc.DSN = cfg.DSN
+
+	// sql.Open does not connect, it only validates the DSN.
+	// Connections are opened lazily and reused between polls.
+	pc.db, pc.dbErr = sql.Open("postgres", cfg.DSN)
+	if pc.dbErr != nil {
+		log.Errorf("Can not open postgresql target %s: %s\n", pc.name, pc.dbErr)
+		return pc
+	}
+	pc.db.SetMaxOpenConns(valueOrDefault(cfg.MaxOpenConns, 1))
+	pc.db.SetMaxIdleConns(valueOrDefault(cfg.MaxIdleConns, 1))
+	pc.db.SetConnMaxLifetime(valueOrDefault(cfg.ConnMaxLifetime, 5*time.Minute))
+
+	// Report a wrong DSN or an unreachable server early, polls connect again anyway.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pc.db.PingContext(ctx); err != nil {
+		log.Errorf("Can not connect to postgresql target %s: %s\n", pc.name, err)
+	}
+	return pc
+}
+
+// pgDSNAddress returns the server address in given URL or key/value DSN to be used as the default target name.
+func pgDSNAddress(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		return u.Host
+	}
+	var host, port string
+	for _, field := range strings.Fields(dsn) {
+		k, v, _ := strings.Cut(field, "=")
+		switch k {
+		case "host":
+			host = v
+		case "port":
+			port = v
+		}
+	}
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Get gets client backends those are connected over TCP from pg_stat_activity, except the backend of kimo itself.
+func (pc *PostgresClient) Get(ctx context.Context) ([]*PostgresRow, error) {
+	if pc.dbErr != nil {
+		return nil, pc.dbErr
+	}
+
+	// client_port is -1 for unix socket connections and null for background processes.
+	// state_change is null if the backend has not reported its state yet.
+	results, err := pc.db.QueryContext(ctx, `select pid, usename, datname, state, query, host(client_addr), client_port, backend_start,
+		coalesce(extract(epoch from now() - state_change), 0)::bigint
+		from pg_stat_activity where client_addr is not null and client_port > 0 and pid <> pg_backend_pid()`)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	prs := make([]*PostgresRow, 0)
+	for results.Next() {
+		var pr PostgresRow
+		var port int32
+		err = results.Scan(&pr.Pid, &pr.User, &pr.DB, &pr.State, &pr.Query, &pr.Address.IP, &port, &pr.BackendStart, &pr.StateTime)
+		if err != nil {
+			return nil, err
+		}
+		pr.Address.IP = normalizeIP(pr.Address.IP)
+		pr.Address.Port = uint32(port)
+		prs = append(prs, &pr)
+	}
+	return prs, results.Err()
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+)
+
+// maxPushSize limits the body of push requests.
+const maxPushSize = 32 << 20
+
+// AgentSnapshot is the process snapshot pushed by an agent running in push mode.
+type AgentSnapshot struct {
+	Hostname  string          `json:"hostname"`
+	Version   string          `json:"version"`
+	IPs       []string        `json:"ips"`
+	Processes []*AgentProcess `json:"processes"`
+
+	received time.Time
+}
+
+// AgentRegistry holds the latest snapshots of agents those push instead of being pulled.
+type AgentRegistry struct {
+	ttl time.Duration // snapshots older than ttl are ignored.
+
+	mu        sync.RWMutex
+	snapshots map[string]*AgentSnapshot // agent IP -> snapshot
+}
+
+// NewAgentRegistry creates and returns a new AgentRegistry.
+func NewAgentRegistry(ttl time.Duration) *AgentRegistry {
+	return &AgentRegistry{
+		ttl:       ttl,
+		snapshots: make(map[string]*AgentSnapshot),
+	}
+}
+
+// Register stores given snapshot for all IPs of the agent and the source IP of the push.
+// The source IP is the address that the database sees if the agent is behind NAT.
+func (r *AgentRegistry) Register(snap *AgentSnapshot, sourceIP string) {
+	snap.received = time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ip, s := range r.snapshots {
+		if time.Since(s.received) > r.ttl {
+			delete(r.snapshots, ip)
+		}
+	}
+	for _, ip := range snap.IPs {
+		r.snapshots[normalizeIP(ip)] = snap
+	}
+	if sourceIP == "" || slices.Contains(snap.IPs, sourceIP) {
+		return
+	}
+	if s, ok := r.snapshots[sourceIP]; ok && s.Hostname != snap.Hostname && time.Since(s.received) <= r.ttl {
+		log.Infof("Agents %s and %s push from the same address %s, the latest one is used\n", s.Hostname, snap.Hostname, sourceIP)
+	}
+	r.snapshots[sourceIP] = snap
+}
+
+// Get returns the snapshot of the agent with given IP, nil if the agent has not pushed recently.
+func (r *AgentRegistry) Get(ip string) *AgentSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snap, ok := r.snapshots[ip]
+	if !ok || time.Since(snap.received) > r.ttl {
+		return nil
+	}
+	return snap
+}
+
+// Push is the handler for agents pushing their process snapshots.
+// Agents are authenticated with a verified client certificate or a token. Agents with a client certificate
+// can only push for the IPs in their certificate, token holders are trusted for the IPs they claim.
+// The snapshot is also registered for the source IP of the request, which is the NAT address of the agent if any.
+func (s *Server) Push(w http.ResponseWriter, req *http.Request) {
+	cert := verifiedClientCert(req)
+	if cert == nil && !s.tokens.ValidRequest(req) {
+		log.Debugf("Unauthorized push from %s\n", req.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kimo"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var snap AgentSnapshot
+	err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxPushSize)).Decode(&snap)
+	if err != nil {
+		http.Error(w, "Can not decode snapshot", http.StatusBadRequest)
+		return
+	}
+	if cert != nil {
+		snap.IPs = certifiedIPs(snap.IPs, cert)
+		if len(snap.IPs) == 0 {
+			http.Error(w, "None of the IPs are in the client certificate", http.StatusForbidden)
+			return
+		}
+	}
+
+	var sourceIP string
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		sourceIP = normalizeIP(host)
+	}
+	s.Fetcher.Registry.Register(&snap, sourceIP)
+	log.Debugf("Agent %s pushed %d processes from %s for %v\n", snap.Hostname, len(snap.Processes), sourceIP, snap.IPs)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifiedClientCert returns the client certificate of the request if it is verified, nil otherwise.
+func verifiedClientCert(req *http.Request) *x509.Certificate {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return req.TLS.VerifiedChains[0][0]
+}
+
+// certifiedIPs returns the IPs those are in the IP addresses of given certificate, others are logged and dropped.
+func certifiedIPs(ips []string, cert *x509.Certificate) []string {
+	var certified []string
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed != nil && slices.ContainsFunc(cert.IPAddresses, parsed.Equal) {
+			certified = append(certified, ip)
+		} else {
+			log.Infof("Pushed IP %s is not in the certificate of %s, dropping it\n", ip, cert.Subject.CommonName)
+		}
+	}
+	return certified
+}
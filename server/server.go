@@ -3,11 +3,12 @@ package server
 import (
 	"context"
 	"fmt"
+	"kimo/auth"
 	"kimo/config"
+	"kimo/tlsutil"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -15,20 +16,57 @@ import (
 	"github.com/cenkalti/log"
 )
 
-// KimoProcess is the final process that is combined with AgentProcess + TCPProxyConn + MysqlProcess
+// KimoProcess is the final process that is combined with AgentProcess + resolved address + database row
 type KimoProcess struct {
-	ID               int32  `json:"id"`
-	MysqlUser        string `json:"mysql_user"`
-	DB               string `json:"db"`
-	Command          string `json:"command"`
-	Time             uint32 `json:"time"`
-	State            string `json:"state"`
-	Info             string `json:"info"`
+	Target         string `json:"target"`
+	ID             int32  `json:"id"`
+	DBUser         string `json:"mysql_user"` // user of the connection in any database, json name is kept for compatibility
+	DB             string `json:"db"`
+	Command        string `json:"command"`
+	Time           uint32 `json:"time"`
+	State          string `json:"state"`
+	Info           string `json:"info"`
+	ClientHost     string `json:"client_host"`
+	ThreadID       int64  `json:"thread_id,omitempty"`
+	ConnectionType string `json:"connection_type,omitempty"`
+	ResourceGroup  string `json:"resource_group,omitempty"`
+
+	BackendStart *time.Time `json:"backend_start,omitempty"` // start time of postgresql backends
+
+	Tags map[string]string `json:"tags,omitempty"` // sqlcommenter or marginalia tags of the query
+
+	TrxState          string      `json:"trx_state,omitempty"`
+	TrxStarted        *time.Time  `json:"trx_started,omitempty"`
+	TrxAge            uint32      `json:"trx_age,omitempty"`
+	TrxRowsLocked     uint64      `json:"trx_rows_locked,omitempty"`
+	TrxRowsModified   uint64      `json:"trx_rows_modified,omitempty"`
+	TrxIsolationLevel string      `json:"trx_isolation_level,omitempty"`
+	BlockedBy         []*LockWait `json:"blocked_by,omitempty"`
+
 	CmdLine          string `json:"cmdline"`
 	ConnectionStatus string `json:"status"`
 	Pid              int    `json:"pid,omitempty"`
 	Host             string `json:"host"`
-	Detail           string `json:"detail"`
+	ContainerID      string `json:"container_id,omitempty"`
+	ContainerRuntime string `json:"container_runtime,omitempty"`
+	ContainerName    string `json:"container_name,omitempty"`
+	PodUID           string `json:"pod_uid,omitempty"`
+	PodName          string `json:"pod_name,omitempty"`
+	PodNamespace     string `json:"pod_namespace,omitempty"`
+
+	UID           *uint32    `json:"uid,omitempty"`
+	Username      string     `json:"username,omitempty"`
+	PPid          int32      `json:"ppid,omitempty"`
+	ParentCmdLine string     `json:"parent_cmdline,omitempty"`
+	StartTime     *time.Time `json:"start_time,omitempty"`
+	Cwd           string     `json:"cwd,omitempty"`
+	Exe           string     `json:"exe,omitempty"`
+	RSS           uint64     `json:"rss,omitempty"`
+	NumFDs        int32      `json:"num_fds,omitempty"`
+
+	EnvTags map[string]string `json:"env_tags,omitempty"` // allowlisted environment variables of the process
+
+	Detail string `json:"detail"`
 }
 
 // Server is a type for handling server side operations
@@ -43,6 +81,9 @@ type Server struct {
 	lastPollError      error
 	healthMutex        sync.RWMutex
 	httpSrv            http.Server
+	tokens             *auth.Tokens
+	tlsFiles           *tlsutil.Files // nil if the server does not serve TLS
+	killUsers          []killUser
 }
 
 // SetProcesses sets kimo processes with lock
@@ -63,20 +104,12 @@ func (s *Server) GetProcesses() []KimoProcess {
 func (s *Server) ConvertProcesses(rps []*RawProcess) []KimoProcess {
 	kps := make([]KimoProcess, 0)
 	for _, rp := range rps {
-		ut, err := strconv.ParseUint(rp.MysqlRow.Time, 10, 32)
-		if err != nil {
-			log.Errorf("time %s could not be converted to int", rp.MysqlRow.Time)
-		}
 		var kp KimoProcess
 
-		// set mysql properties
-		kp.ID = rp.MysqlRow.ID
-		kp.MysqlUser = rp.MysqlRow.User
-		kp.DB = rp.MysqlRow.DB.String
-		kp.Command = rp.MysqlRow.Command
-		kp.Time = uint32(ut)
-		kp.State = rp.MysqlRow.State.String
-		kp.Info = rp.MysqlRow.Info.String
+		// set database properties
+		kp.Target = rp.Target
+		rp.Row.Fill(&kp)
+		kp.Tags = parseQueryTags(kp.Info)
 
 		// set process properties
 		if rp.Process != nil {
@@ -84,6 +117,22 @@ func (s *Server) ConvertProcesses(rps []*RawProcess) []KimoProcess {
 			kp.ConnectionStatus = rp.Process.ConnectionStatus
 			kp.Pid = int(rp.Process.Pid)
 			kp.Host = rp.Process.Host()
+			kp.ContainerID = rp.Process.ContainerID
+			kp.ContainerRuntime = rp.Process.ContainerRuntime
+			kp.ContainerName = rp.Process.ContainerName
+			kp.PodUID = rp.Process.PodUID
+			kp.PodName = rp.Process.PodName
+			kp.PodNamespace = rp.Process.PodNamespace
+			kp.UID = rp.Process.UID
+			kp.Username = rp.Process.Username
+			kp.PPid = rp.Process.PPid
+			kp.ParentCmdLine = rp.Process.ParentCmdline
+			kp.StartTime = rp.Process.StartTime
+			kp.Cwd = rp.Process.Cwd
+			kp.Exe = rp.Process.Exe
+			kp.RSS = rp.Process.RSS
+			kp.NumFDs = rp.Process.NumFDs
+			kp.EnvTags = rp.Process.Tags
 		}
 
 		// set misc.
@@ -98,17 +147,41 @@ func (s *Server) ConvertProcesses(rps []*RawProcess) []KimoProcess {
 func NewServer(cfg *config.ServerConfig) *Server {
 	s := &Server{
 		Config:           cfg,
-		PrometheusMetric: NewPrometheusMetric(cfg.Metric.CmdlinePatterns),
+		PrometheusMetric: NewPrometheusMetric(cfg.Metric),
 		processes:        make([]KimoProcess, 0),
 		AgentListenPort:  cfg.Agent.Port,
 	}
 	s.Fetcher = NewFetcher(*s.Config)
+	s.Fetcher.Statuses.observer = s.PrometheusMetric
 
 	// create http server
+	tokens := auth.NewTokens(cfg.Auth)
+	s.tokens = tokens
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		s.tlsFiles = tlsutil.NewFiles(cfg.TLS)
+		s.tlsFiles.OptionalClientCert = true // browsers do not have client certificates.
+	}
 	mux := http.NewServeMux()
-	mux.Handle("/", s.Static())
-	mux.Handle("/metrics", s.Metrics())
-	mux.HandleFunc("/procs", s.Procs)
+	mux.Handle("/", tokens.Handler(s.Static()))
+	mux.Handle("/metrics", tokens.Handler(s.Metrics()))
+	mux.Handle("/procs", tokens.HandlerFunc(s.Procs))
+	mux.Handle("/locks", tokens.HandlerFunc(s.Locks))
+	mux.Handle("GET /agents", tokens.HandlerFunc(s.Agents))
+	if cfg.Agent.Push.Enabled {
+		if tokens.Enabled() || s.clientCertsVerified() {
+			mux.HandleFunc("POST /agents/push", s.Push)
+		} else {
+			log.Errorln("Agent push is disabled, it requires auth tokens or a CA file to verify client certificates")
+		}
+	}
+	if len(cfg.Kill.Users) > 0 {
+		if s.tlsFiles != nil {
+			s.killUsers = loadKillUsers(cfg.Kill.Users)
+			mux.HandleFunc("POST /procs/{id}/kill", s.Kill)
+		} else {
+			log.Errorln("Kill endpoint is disabled, it requires tls to be configured")
+		}
+	}
 	mux.HandleFunc("/health", s.Health)
 	s.httpSrv = http.Server{
 		Addr:    s.Config.ListenAddress,
@@ -118,6 +191,11 @@ func NewServer(cfg *config.ServerConfig) *Server {
 	return s
 }
 
+// clientCertsVerified returns true if the server verifies client certificates.
+func (s *Server) clientCertsVerified() bool {
+	return s.tlsFiles != nil && s.Config.TLS.CAFile != ""
+}
+
 // Run starts the server and begins listening for HTTP requests.
 func (s *Server) Run() error {
 	log.Infof("Running server on %s \n", s.Config.ListenAddress)
@@ -126,9 +204,23 @@ func (s *Server) Run() error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	if s.tlsFiles != nil {
+		tlsConfig, err := s.tlsFiles.ServerConfig()
+		if err != nil {
+			return fmt.Errorf("tls config error: %w", err)
+		}
+		s.httpSrv.TLSConfig = tlsConfig
+	}
+
 	// Start server in a goroutine
 	go func() {
-		if err := s.httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.httpSrv.TLSConfig != nil {
+			err = s.httpSrv.ListenAndServeTLS("", "") // certificates are given by TLSConfig.
+		} else {
+			err = s.httpSrv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errChan <- fmt.Errorf("http server error: %w", err)
 		}
 	}()
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"kimo/config"
+	"sort"
+)
+
+// Row is a client connection reported by a database.
+type Row interface {
+	// ClientAddress returns the address of the client as the database sees it.
+	ClientAddress() IPPort
+	// Fill sets database specific properties of given kimo process.
+	Fill(kp *KimoProcess)
+}
+
+// SocketRow is implemented by rows those can be unix socket connections.
+type SocketRow interface {
+	// SocketFD returns fd of the connection in the database server process, 0 for TCP connections.
+	SocketFD() int32
+}
+
+// ServerRow is implemented by rows those know the address of the database server the client is connected to.
+type ServerRow interface {
+	// ServerAddress returns the server address from the client's point of view, zero value if unknown.
+	ServerAddress() IPPort
+}
+
+// IDRow is implemented by rows those have a connection id, which changes when the client reconnects.
+type IDRow interface {
+	// ConnectionID returns the id of the connection in the database.
+	ConnectionID() int32
+}
+
+// Source is a database that client connections are fetched from.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Row, error)
+}
+
+// Hop is an intermediary (e.g. a TCP proxy) that clients connect to databases through.
+type Hop interface {
+	Name() string
+	// Resolve maps given addresses on the database side of the hop to the connections on the client side.
+	// Addresses those are not found on the hop are omitted from the result.
+	Resolve(ctx context.Context, addrs []IPPort) (map[IPPort]HopConn, error)
+}
+
+// HopConn is a connection between a client and a hop.
+type HopConn struct {
+	Client IPPort // address of the client.
+	Server IPPort // address of the hop that the client is connected to, zero value if unknown.
+}
+
+// Killer is implemented by sources those can kill queries and connections.
+type Killer interface {
+	Kill(ctx context.Context, id int32, mode string) error
+}
+
+// SourceFactory creates sources from the server configuration.
+type SourceFactory func(cfg config.ServerConfig) []Source
+
+// HopFactory creates hops from the server configuration.
+type HopFactory func(cfg config.ServerConfig) []Hop
+
+var (
+	sourceFactories = make(map[string]SourceFactory)
+	hopFactories    = make(map[string]HopFactory)
+)
+
+// RegisterSource registers a source type. It is meant to be called from init functions.
+func RegisterSource(name string, factory SourceFactory) {
+	sourceFactories[name] = factory
+}
+
+// RegisterHop registers a hop type. It is meant to be called from init functions.
+func RegisterHop(name string, factory HopFactory) {
+	hopFactories[name] = factory
+}
+
+// newSources creates sources of all registered types in name order.
+func newSources(cfg config.ServerConfig) []Source {
+	var sources []Source
+	for _, name := range sortedKeys(sourceFactories) {
+		sources = append(sources, sourceFactories[name](cfg)...)
+	}
+	return sources
+}
+
+// newHops creates hops of all registered types in name order.
+func newHops(cfg config.ServerConfig) []Hop {
+	var hops []Hop
+	for _, name := range sortedKeys(hopFactories) {
+		hops = append(hops, hopFactories[name](cfg)...)
+	}
+	return hops
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
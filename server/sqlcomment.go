@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	sqlCommentRegexp = regexp.MustCompile(`(?s)/\*(.*?)\*/`)
+	// sqlcommenter pairs: key='value', values are url encoded and single quotes are escaped.
+	sqlcommenterRegexp = regexp.MustCompile(`\s*([^\s=,']+)='((?:[^'\\]|\\.)*)'\s*(?:,|$)`)
+)
+
+// parseQueryTags parses tags from sqlcommenter (/*app='x',controller='y'*/) or
+// marginalia (/*application:x,controller:y*/) style comments in given query.
+// If the query contains more than one tag comment, later ones override earlier ones.
+func parseQueryTags(query string) map[string]string {
+	if !strings.Contains(query, "/*") {
+		return nil
+	}
+
+	var tags map[string]string
+	for _, m := range sqlCommentRegexp.FindAllStringSubmatch(query, -1) {
+		comment := strings.TrimSpace(m[1])
+		parsed := parseSQLCommenter(comment)
+		if parsed == nil {
+			parsed = parseMarginalia(comment)
+		}
+		for k, v := range parsed {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// parseSQLCommenter parses a comment in sqlcommenter format. Returns nil if the comment is not in that format.
+func parseSQLCommenter(comment string) map[string]string {
+	matches := sqlcommenterRegexp.FindAllStringSubmatchIndex(comment, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(matches))
+	end := 0
+	for _, m := range matches {
+		if m[0] != end { // there is something between pairs, not a sqlcommenter comment.
+			return nil
+		}
+		end = m[1]
+		key := unescapeSQLCommenter(comment[m[2]:m[3]])
+		value := unescapeSQLCommenter(comment[m[4]:m[5]])
+		tags[key] = value
+	}
+	if end != len(comment) {
+		return nil
+	}
+	return tags
+}
+
+// unescapeSQLCommenter reverses the meta character escaping and the url encoding of sqlcommenter.
+// Values are percent-encoded, "+" is kept as is unlike query strings.
+func unescapeSQLCommenter(s string) string {
+	s = strings.ReplaceAll(s, `\'`, `'`)
+	unescaped, err := url.PathUnescape(s)
+	if err != nil {
+		return s
+	}
+	return unescaped
+}
+
+// parseMarginalia parses a comment in marginalia format. Returns nil if the comment is not in that format.
+func parseMarginalia(comment string) map[string]string {
+	if comment == "" {
+		return nil
+	}
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(comment, ",") {
+		key, value, ok := strings.Cut(pair, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t\n") {
+			return nil
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseQueryTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  map[string]string
+	}{
+		{"no comment", "SELECT 1", nil},
+		{"empty comment", "SELECT 1 /**/", nil},
+		{"sqlcommenter", "SELECT 1 /*app='billing',controller='invoices'*/",
+			map[string]string{"app": "billing", "controller": "invoices"}},
+		{"sqlcommenter url encoded", "SELECT 1 /*route='%2Fapi%2Fv1',framework='rails%3A7+1'*/",
+			map[string]string{"route": "/api/v1", "framework": "rails:7+1"}},
+		{"sqlcommenter escaped quote", `SELECT 1 /*action='it\'s'*/`,
+			map[string]string{"action": "it's"}},
+		{"marginalia", "SELECT 1 /*application:billing,controller:invoices,action:show*/",
+			map[string]string{"application": "billing", "controller": "invoices", "action": "show"}},
+		{"comment before query", "/* app='billing' */ SELECT 1",
+			map[string]string{"app": "billing"}},
+		{"later comment overrides", "/*app='a',job='x'*/ SELECT 1 /*app='b'*/",
+			map[string]string{"app": "b", "job": "x"}},
+		{"not a tag comment", "SELECT 1 /* just a note */", nil},
+		{"garbage between pairs", "SELECT 1 /*app='a' junk job='x'*/", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseQueryTags(tt.query)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseQueryTags(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -25,6 +25,15 @@ type TCPConnResponse struct {
 	Records []*TCPProxyConn `json:"conns"`
 }
 
+func init() {
+	RegisterHop("tcpproxy", func(cfg config.ServerConfig) []Hop {
+		if cfg.TCPProxy.MgmtAddress == "" {
+			return nil
+		}
+		return []Hop{NewTCPProxyClient(cfg.TCPProxy)}
+	})
+}
+
 // TCPProxyClient represents a TCPProxy client that manages connection details and stores TCPProxy management results.
 type TCPProxyClient struct {
 	MgmtAddress string
@@ -64,6 +73,32 @@ func (tc *TCPProxyClient) Get(ctx context.Context) ([]*TCPProxyConn, error) {
 	return conns.Records, nil
 }
 
+// Name returns the name of the hop.
+func (tc *TCPProxyClient) Name() string {
+	return "tcpproxy"
+}
+
+// Resolve maps given proxy out addresses to client out and proxy in addresses using TCPProxy connection records.
+func (tc *TCPProxyClient) Resolve(ctx context.Context, addrs []IPPort) (map[IPPort]HopConn, error) {
+	conns, err := tc.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Got %d tcpproxy conns \n", len(conns))
+
+	resolved := make(map[IPPort]HopConn)
+	for _, addr := range addrs {
+		conn := findTCPProxyConn(addr, conns)
+		if conn != nil {
+			resolved[addr] = HopConn{
+				Client: IPPort{IP: normalizeIP(conn.ClientOut.IP), Port: conn.ClientOut.Port},
+				Server: IPPort{IP: normalizeIP(conn.ProxyIn.IP), Port: conn.ProxyIn.Port},
+			}
+		}
+	}
+	return resolved, nil
+}
+
 func findHostIP(host string) (string, error) {
 	ip := net.ParseIP(host)
 	if ip == nil {
@@ -71,9 +106,9 @@ func findHostIP(host string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		return string(ips[0].String()), nil
+		return normalizeIP(ips[0].String()), nil
 	}
-	return ip.String(), nil
+	return normalizeIP(ip.String()), nil
 }
 
 func findTCPProxyConn(addr IPPort, proxyConns []*TCPProxyConn) *TCPProxyConn {
@@ -84,7 +119,7 @@ func findTCPProxyConn(addr IPPort, proxyConns []*TCPProxyConn) *TCPProxyConn {
 	}
 
 	for _, conn := range proxyConns {
-		if conn.ProxyOut.IP == ipAddr && conn.ProxyOut.Port == addr.Port {
+		if normalizeIP(conn.ProxyOut.IP) == ipAddr && conn.ProxyOut.Port == addr.Port {
 			return conn
 		}
 	}
@@ -1,7 +1,60 @@
 package server
 
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
 // IPPort is an address type that is used to define a host
 type IPPort struct {
 	IP   string `json:"ip"`
 	Port uint32 `json:"port"`
 }
+
+// String returns the address in host:port form, IPv6 addresses are enclosed in brackets.
+func (a IPPort) String() string {
+	return net.JoinHostPort(a.IP, strconv.FormatUint(uint64(a.Port), 10))
+}
+
+// parseHostPort parses an address in host:port form as mysql reports it.
+// Host might be an IPv4 address, a hostname or an IPv6 address with or without brackets
+// (e.g. [2001:db8::1]:51234 or 2001:db8::1:51234). Port is always the part after the last colon.
+func parseHostPort(hostport string) (IPPort, error) {
+	var host, port string
+	if strings.HasPrefix(hostport, "[") {
+		var err error
+		host, port, err = net.SplitHostPort(hostport)
+		if err != nil {
+			return IPPort{}, err
+		}
+	} else {
+		i := strings.LastIndex(hostport, ":")
+		if i < 0 {
+			return IPPort{}, fmt.Errorf("missing port in address %s", hostport)
+		}
+		host, port = hostport[:i], hostport[i+1:]
+	}
+	if host == "" {
+		return IPPort{}, fmt.Errorf("missing host in address %s", hostport)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return IPPort{}, fmt.Errorf("invalid port in address %s: %w", hostport, err)
+	}
+	return IPPort{IP: normalizeIP(host), Port: uint32(p)}, nil
+}
+
+// normalizeIP returns the canonical form of given IP so that addresses from different resources can be compared.
+// IPv4-mapped IPv6 addresses (::ffff:10.0.0.1) are converted to IPv4. Hostnames are returned as they are.
+func normalizeIP(host string) string {
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	if ip == nil {
+		return host
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String()
+	}
+	return ip.String()
+}
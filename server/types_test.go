@@ -0,0 +1,56 @@
+package server
+
+import "testing"
+
+func TestParseHostPort(t *testing.T) {
+	tests := []struct {
+		hostport string
+		want     IPPort
+		wantErr  bool
+	}{
+		{"10.0.0.5:51234", IPPort{IP: "10.0.0.5", Port: 51234}, false},
+		{"app-1.example.com:51234", IPPort{IP: "app-1.example.com", Port: 51234}, false},
+		{"[2001:db8::1]:51234", IPPort{IP: "2001:db8::1", Port: 51234}, false},
+		{"2001:db8::1:51234", IPPort{IP: "2001:db8::1", Port: 51234}, false},
+		{"[::ffff:10.0.0.5]:51234", IPPort{IP: "10.0.0.5", Port: 51234}, false},
+		{"::ffff:10.0.0.5:51234", IPPort{IP: "10.0.0.5", Port: 51234}, false},
+		{"10.0.0.5", IPPort{}, true},
+		{":51234", IPPort{}, true},
+		{"10.0.0.5:port", IPPort{}, true},
+		{"10.0.0.5:70000", IPPort{}, true},
+		{"[2001:db8::1:51234", IPPort{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hostport, func(t *testing.T) {
+			got, err := parseHostPort(tt.hostport)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHostPort(%q) error = %v, want error %t", tt.hostport, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseHostPort(%q) = %v, want %v", tt.hostport, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"10.0.0.5", "10.0.0.5"},
+		{"::ffff:10.0.0.5", "10.0.0.5"},
+		{"[::ffff:10.0.0.5]", "10.0.0.5"},
+		{"2001:DB8:0:0::1", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"localhost", "localhost"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := normalizeIP(tt.host); got != tt.want {
+				t.Errorf("normalizeIP(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Package tlsutil builds TLS configurations from certificate files and reloads them when the files change.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"kimo/config"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/log"
+)
+
+// checkInterval is the minimum duration between checks of file modification times.
+const checkInterval = 5 * time.Second
+
+// Files holds the certificate, key and CA loaded from the files in config.
+// Files are checked for changes at most once in checkInterval when a configuration is requested.
+type Files struct {
+	Config config.TLSConfig
+	// Client certificates are verified only if they are given instead of being required,
+	// e.g. for servers those serve browsers too.
+	OptionalClientCert bool
+
+	mu       sync.Mutex
+	checked  time.Time
+	modTimes map[string]time.Time
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+}
+
+// NewFiles creates and returns a new Files for given config.
+func NewFiles(cfg config.TLSConfig) *Files {
+	return &Files{Config: cfg}
+}
+
+// Load loads the files if they are not loaded yet or they are changed.
+func (f *Files) Load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load()
+}
+
+func (f *Files) load() error {
+	paths := []string{f.Config.CertFile, f.Config.KeyFile, f.Config.CAFile}
+	modTimes := make(map[string]time.Time)
+	changed := f.modTimes == nil
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[path] = fi.ModTime()
+		if !fi.ModTime().Equal(f.modTimes[path]) {
+			changed = true
+		}
+	}
+	f.checked = time.Now()
+	if !changed {
+		return nil
+	}
+
+	var cert *tls.Certificate
+	if f.Config.CertFile != "" || f.Config.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(f.Config.CertFile, f.Config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("can not load certificate: %w", err)
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if f.Config.CAFile != "" {
+		pem, err := os.ReadFile(f.Config.CAFile)
+		if err != nil {
+			return fmt.Errorf("can not read CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in CA file %s", f.Config.CAFile)
+		}
+	}
+
+	if f.modTimes != nil {
+		log.Infoln("TLS files are reloaded")
+	}
+	f.cert, f.pool, f.modTimes = cert, pool, modTimes
+	return nil
+}
+
+// reload loads the files again if they are changed and check interval is passed.
+// Errors are logged and previously loaded files are kept.
+func (f *Files) reload() {
+	if f.modTimes != nil && time.Since(f.checked) < checkInterval {
+		return
+	}
+	err := f.load()
+	if err != nil {
+		log.Errorf("Can not reload TLS files: %s\n", err)
+	}
+}
+
+// ServerConfig returns a TLS configuration for servers. Client certificates are required
+// and verified if a CA file is configured. Reloaded files are used for new connections.
+// nextProtos are the ALPN protocols to be negotiated, e.g. "h2" for gRPC.
+func (f *Files) ServerConfig(nextProtos ...string) (*tls.Config, error) {
+	if f.Config.CertFile == "" || f.Config.KeyFile == "" {
+		return nil, errors.New("cert_file and key_file are required")
+	}
+	err := f.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.reload()
+			c := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*f.cert},
+				NextProtos:   nextProtos,
+			}
+			if f.pool != nil {
+				c.ClientCAs = f.pool
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+				if f.OptionalClientCert {
+					c.ClientAuth = tls.VerifyClientCertIfGiven
+				}
+			}
+			return c, nil
+		},
+	}, nil
+}
+
+// ClientConfig returns a TLS configuration for clients connecting to given host (name or IP).
+// Server certificates are verified for ServerName in config if it is set, for host otherwise;
+// against the CA file if it is configured, system roots otherwise. The certificate and the CA are
+// taken from the files on every handshake, so long-lived configurations use reloaded files too.
+func (f *Files) ClientConfig(host string) (*tls.Config, error) {
+	err := f.Load()
+	if err != nil {
+		return nil, err
+	}
+	serverName := f.Config.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.reload()
+			if f.cert == nil {
+				return &tls.Certificate{}, nil // no client certificate is sent.
+			}
+			return f.cert, nil
+		},
+		// Default verification is replaced by VerifyConnection to use the current CA.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			f.mu.Lock()
+			f.reload()
+			pool := f.pool
+			f.mu.Unlock()
+			return verifyServer(cs, serverName, pool)
+		},
+	}, nil
+}
+
+// verifyServer verifies the server certificate of given connection for the name with the CA pool,
+// system roots if it is nil.
+func verifyServer(cs tls.ConnectionState, serverName string, pool *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server did not send a certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}